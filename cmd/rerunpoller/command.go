@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	actionRerun  = "rerun"
+	actionStatus = "status"
+	actionCancel = "cancel"
+	actionHelp   = "help"
+
+	rerunPrefix = "rerun:"
+	failedAlias = "failed"
+)
+
+var validArchs = []string{"amd64", "arm64"}
+
+const helpText = "*Rerun poller commands* (reply in this thread):\n" +
+	"• `rerun: failed` - rerun all failed tests from the last run\n" +
+	"• `rerun: <test>[,<test>...]` - rerun specific failed tests\n" +
+	"• `rerun: <suite>` - rerun any suite from the last run, even if it passed\n" +
+	"• `rerun: failed --arch arm64` - rerun on a specific architecture (amd64, arm64)\n" +
+	"• `status` - show the running tests and the failed tests from the last run\n" +
+	"• `cancel` - drop the queued reruns and stop the running ones, other test runs are not affected\n" +
+	"• `help` - show this message"
+
+// rerunCommand is a parsed thread reply.
+type rerunCommand struct {
+	action string
+	tests  []string
+	arch   string
+}
+
+// testList returns the tests in the format expected by run_tests.sh.
+func (c *rerunCommand) testList() string {
+	return strings.Join(c.tests, ",")
+}
+
+// parseRerunRequest parses a thread reply into a command.
+// It returns nil without error when the message is not meant for the poller,
+// and an error when the message is a command that can not be honored.
+//
+// Keywords and options are case-insensitive, test names are validated against the failed tests saved in the state
+// and the suites from the last run and resolved to their canonical name.
+func parseRerunRequest(text string, state *rerunState, knownSuites []string) (*rerunCommand, error) {
+	text = strings.TrimSpace(text)
+
+	switch strings.ToLower(text) {
+	case actionStatus, rerunPrefix + " " + actionStatus, rerunPrefix + actionStatus:
+		return &rerunCommand{action: actionStatus}, nil
+	case actionCancel, rerunPrefix + " " + actionCancel, rerunPrefix + actionCancel:
+		return &rerunCommand{action: actionCancel}, nil
+	case actionHelp, rerunPrefix + " " + actionHelp, rerunPrefix + actionHelp:
+		return &rerunCommand{action: actionHelp}, nil
+	}
+
+	if len(text) < len(rerunPrefix) || !strings.EqualFold(text[:len(rerunPrefix)], rerunPrefix) {
+		return nil, nil
+	}
+
+	args := strings.Fields(strings.ReplaceAll(text[len(rerunPrefix):], ",", " "))
+	if len(args) == 0 {
+		return nil, errors.New("missing tests to rerun, use `rerun: failed` or `rerun: <test>`")
+	}
+
+	cmd := &rerunCommand{action: actionRerun}
+	var names []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		option := strings.ToLower(arg)
+
+		switch {
+		case option == "--arch":
+			if i+1 >= len(args) {
+				return nil, errors.New("`--arch` requires a value: " + strings.Join(validArchs, ", "))
+			}
+			i++
+			cmd.arch = strings.ToLower(args[i])
+		case strings.HasPrefix(option, "--arch="):
+			cmd.arch = strings.TrimPrefix(option, "--arch=")
+		case strings.HasPrefix(option, "--"):
+			return nil, fmt.Errorf("unknown option `%s`", arg)
+		default:
+			names = append(names, arg)
+		}
+	}
+
+	if cmd.arch != "" && !slices.Contains(validArchs, cmd.arch) {
+		return nil, fmt.Errorf("invalid arch `%s`, valid values: %s", cmd.arch, strings.Join(validArchs, ", "))
+	}
+
+	tests, err := resolveTests(names, state, knownSuites)
+	if err != nil {
+		return nil, err
+	}
+	cmd.tests = tests
+
+	return cmd, nil
+}
+
+// resolveTests maps the requested names to test directories, expanding the "failed" alias.
+func resolveTests(names []string, state *rerunState, knownSuites []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, errors.New("missing tests to rerun, use `rerun: failed` or `rerun: <test>`")
+	}
+
+	var (
+		tests   []string
+		unknown []string
+	)
+
+	for _, name := range names {
+		if strings.EqualFold(name, failedAlias) {
+			if len(state.FailedTests) == 0 {
+				return nil, errors.New("no failed tests recorded from the last run")
+			}
+			tests = append(tests, state.FailedTests...)
+			continue
+		}
+
+		canonical, found := findFold(slices.Concat(state.FailedTests, knownSuites), name)
+		if !found {
+			unknown = append(unknown, name)
+			continue
+		}
+		tests = append(tests, canonical)
+	}
+
+	if len(unknown) > 0 {
+		valid := append(append([]string{}, state.FailedTests...), knownSuites...)
+		slices.Sort(valid)
+
+		return nil, fmt.Errorf("unknown tests: `%s`\nValid tests: `%s`",
+			strings.Join(unknown, "`, `"), strings.Join(slices.Compact(valid), "`, `"))
+	}
+
+	slices.Sort(tests)
+
+	return slices.Compact(tests), nil
+}
+
+// loadKnownSuites reads the suites executed in the last run from the .test-dirs.txt file next to the state file.
+func loadKnownSuites(stateFile string) []string {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(stateFile), ".test-dirs.txt"))
+	if err != nil {
+		return nil
+	}
+
+	var suites []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			suites = append(suites, line)
+		}
+	}
+
+	return suites
+}
+
+// statusMessage builds the reply for the status command.
+func statusMessage(state *rerunState, queueDir string) string {
+	var sb strings.Builder
+
+	if running, reason := isTestsRunning(); running {
+		sb.WriteString(":hourglass_flowing_sand: Tests are running: " + reason + "\n")
+	} else {
+		sb.WriteString(":zzz: No tests are running\n")
+	}
+
+	if len(state.FailedTests) == 0 {
+		sb.WriteString("No failed tests recorded from the last run\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Failed tests from the last run: `%s`\n", strings.Join(state.FailedTests, "`, `")))
+	}
+
+	if err := withQueue(queueDir, func(q *rerunQueue, h *rerunHistory) error {
+		sb.WriteString("\n" + q.summary(h))
+		return nil
+	}); err != nil {
+		shared.LogLevel("warn", "Failed to read rerun queue: %v", err)
+	}
+
+	return sb.String()
+}
+
+// enqueueMessage adds the rerun to the queue and builds the reply with the queued job or the rejection reason.
+func enqueueMessage(queueDir string, state *rerunState, cmd *rerunCommand, msg *slackMessage, cfg queueConfig) string {
+	var (
+		job      *rerunJob
+		position int
+	)
+
+	err := withQueue(queueDir, func(q *rerunQueue, h *rerunHistory) error {
+		var enqueueErr error
+		job, enqueueErr = q.enqueue(h, state, cmd, msg, cfg)
+		position = len(q.jobsIn(jobQueued))
+
+		return enqueueErr
+	})
+	if err != nil {
+		shared.LogLevel("warn", "Rerun request from %s rejected: %v", msg.User, err)
+		return fmt.Sprintf(":no_entry: <@%s> rerun not queued: %v", msg.User, err)
+	}
+
+	shared.LogLevel("info", "Queued rerun #%d: ts=%s, user=%s, tests='%s', arch='%s'",
+		job.ID, msg.TS, msg.User, cmd.testList(), cmd.arch)
+
+	return fmt.Sprintf(":inbox_tray: Queued rerun %s (position %d in queue)", job.describe(), position)
+}
+
+// cancelMessage cancels the queued and running reruns and builds the reply.
+// Only the jobs of the rerun queue are cancelled, tests started outside of the queue are left running.
+func cancelMessage(queueDir, user string) string {
+	reply := ":octagonal_sign: Cancel requested by <@" + user + ">: "
+
+	var res string
+	err := withQueue(queueDir, func(q *rerunQueue, h *rerunHistory) error {
+		var cancelErr error
+		res, cancelErr = q.cancel(h, user)
+
+		return cancelErr
+	})
+	switch {
+	case errors.Is(err, errNothingToCancel):
+		return reply + "nothing to cancel"
+	case err != nil:
+		shared.LogLevel("warn", "Failed to cancel reruns: %v", err)
+		return reply + "failed to cancel reruns: " + err.Error()
+	}
+
+	return reply + res
+}
+
+// findFold returns the entry of the list equal to name under case folding.
+func findFold(list []string, name string) (string, bool) {
+	i := slices.IndexFunc(list, func(s string) bool {
+		return strings.EqualFold(s, name)
+	})
+	if i < 0 {
+		return "", false
+	}
+
+	return list[i], true
+}
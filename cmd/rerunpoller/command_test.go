@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRerunRequest(t *testing.T) {
+	state := &rerunState{FailedTests: []string{"validatecluster", "upgradeCluster"}}
+	knownSuites := []string{"certrotate", "secretsEncrypt"}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    *rerunCommand
+		wantErr string
+	}{
+		{name: "not a command", text: "looks flaky to me"},
+		{name: "status", text: "status", want: &rerunCommand{action: actionStatus}},
+		{name: "status with prefix", text: "Rerun: Status", want: &rerunCommand{action: actionStatus}},
+		{name: "cancel", text: " CANCEL ", want: &rerunCommand{action: actionCancel}},
+		{name: "help with prefix", text: "rerun:help", want: &rerunCommand{action: actionHelp}},
+		{
+			name: "failed alias",
+			text: "rerun: failed",
+			want: &rerunCommand{action: actionRerun, tests: []string{"upgradeCluster", "validatecluster"}},
+		},
+		{
+			name: "canonical names",
+			text: "RERUN: UpgradeCluster, SECRETSENCRYPT",
+			want: &rerunCommand{action: actionRerun, tests: []string{"secretsEncrypt", "upgradeCluster"}},
+		},
+		{
+			name: "duplicates removed",
+			text: "rerun: failed validatecluster",
+			want: &rerunCommand{action: actionRerun, tests: []string{"upgradeCluster", "validatecluster"}},
+		},
+		{
+			name: "arch option",
+			text: "rerun: certrotate --ARCH ARM64",
+			want: &rerunCommand{action: actionRerun, tests: []string{"certrotate"}, arch: "arm64"},
+		},
+		{
+			name: "arch option with equals",
+			text: "rerun: certrotate --arch=amd64",
+			want: &rerunCommand{action: actionRerun, tests: []string{"certrotate"}, arch: "amd64"},
+		},
+		{name: "missing tests", text: "rerun:", wantErr: "missing tests"},
+		{name: "only options", text: "rerun: --arch arm64", wantErr: "missing tests"},
+		{name: "missing arch value", text: "rerun: failed --arch", wantErr: "requires a value"},
+		{name: "invalid arch", text: "rerun: failed --arch s390x", wantErr: "invalid arch"},
+		{name: "unknown option", text: "rerun: failed --force", wantErr: "unknown option `--force`"},
+		{name: "unknown test", text: "rerun: nvidia", wantErr: "unknown tests: `nvidia`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRerunRequest(tt.text, state, knownSuites)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.want == nil || got == nil {
+				if tt.want != got {
+					t.Fatalf("expected %+v, got %+v", tt.want, got)
+				}
+				return
+			}
+			if got.action != tt.want.action || got.arch != tt.want.arch || !slices.Equal(got.tests, tt.want.tests) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRerunRequestNoFailedTests(t *testing.T) {
+	_, err := parseRerunRequest("rerun: failed", &rerunState{}, []string{"certrotate"})
+	if err == nil || !strings.Contains(err.Error(), "no failed tests") {
+		t.Fatalf("expected no failed tests error, got %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	shutdownTimeout  = 30 * time.Second
+	maxEventAge      = 5 * time.Minute
+	maxEventBodySize = 1 << 20
+	eventBufferSize  = 100
+)
+
+// slackEvent is the inner event of an Events API callback.
+type slackEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype,omitempty"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	BotID    string `json:"bot_id,omitempty"`
+}
+
+// slackEventCallback is the Events API payload, delivered over HTTP or inside a Socket Mode envelope.
+type slackEventCallback struct {
+	Type      string     `json:"type"`
+	Challenge string     `json:"challenge,omitempty"`
+	Event     slackEvent `json:"event"`
+}
+
+// daemon keeps the rerun state in memory, persisted to the state file,
+// and handles thread replies as Slack delivers them instead of polling.
+type daemon struct {
+	slackToken    string
+	appToken      string
+	signingSecret string
+	stateFile     string
+	cfg           queueConfig
+
+	mu          sync.Mutex
+	state       *rerunState
+	stateMod    time.Time
+	knownSuites []string
+	connected   bool
+
+	events chan slackEvent
+	wake   chan struct{}
+}
+
+// runDaemon runs until SIGTERM or SIGINT, receiving thread replies over Socket Mode when SLACK_APP_TOKEN is set
+// and over the Events API endpoint when SLACK_SIGNING_SECRET is set.
+func runDaemon(addr string) error {
+	d := &daemon{
+		slackToken:    os.Getenv("SLACK_TOKEN"),
+		appToken:      os.Getenv("SLACK_APP_TOKEN"),
+		signingSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+		stateFile:     filepath.Join(getHomeDir(), "distros-test-framework", "report", ".rerun-state.json"),
+		cfg:           loadQueueConfig(),
+		events:        make(chan slackEvent, eventBufferSize),
+		wake:          make(chan struct{}, 1),
+	}
+
+	if d.slackToken == "" {
+		return errors.New("SLACK_TOKEN environment variable not set")
+	}
+	if d.appToken == "" && d.signingSecret == "" {
+		return errors.New("SLACK_APP_TOKEN (Socket Mode) or SLACK_SIGNING_SECRET (Events API) must be set")
+	}
+
+	// holding the poller lock keeps cron pollers from handling the same replies.
+	if !acquireLock(pollLockFile, "poller") {
+		return errors.New("another poller instance is running")
+	}
+	defer releaseLock(pollLockFile, "poller")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	shared.LogLevel("info", "\nRerun Daemon Starting on %s (state file: %s)\n", addr, d.stateFile)
+
+	// catch up on replies posted while the daemon was down.
+	if state := d.refreshState(); state != nil {
+		if err := processThreadReplies(state, d.stateFile, d.slackToken, d.knownSuites, d.cfg); err != nil {
+			shared.LogLevel("warn", "Failed to catch up on thread replies: %v", err)
+		}
+		d.wakeRunner()
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           d.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	var wg sync.WaitGroup
+	if d.appToken != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.runSocketMode(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.runRunner(ctx)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		shared.LogLevel("info", "Shutdown signal received, stopping rerun daemon")
+	case err = <-serveErr:
+		shared.LogLevel("error", "HTTP server failed: %v", err)
+		stop()
+	case <-d.processEvents(ctx):
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+		shared.LogLevel("warn", "HTTP server shutdown: %v", shutdownErr)
+	}
+	wg.Wait()
+
+	shared.LogLevel("info", "Rerun daemon stopped")
+
+	return err
+}
+
+// processEvents handles the received events one at a time until ctx is cancelled.
+// The returned channel is closed once it stops.
+func (d *daemon) processEvents(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-d.events:
+				d.handleEvent(&ev)
+			}
+		}
+	}()
+
+	return done
+}
+
+// handleEvent handles a message event if it is a reply in the tracked thread.
+func (d *daemon) handleEvent(ev *slackEvent) {
+	stats.eventReceived()
+
+	state := d.refreshState()
+	if state == nil {
+		shared.LogLevel("debug", "No rerun state loaded - ignoring event ts=%s", ev.TS)
+		return
+	}
+	if ev.Channel != state.ChannelID || ev.ThreadTS != state.ThreadTS {
+		return
+	}
+
+	msg := &slackMessage{TS: ev.TS, User: ev.User, Text: ev.Text, BotID: ev.BotID}
+
+	d.mu.Lock()
+	knownSuites := d.knownSuites
+	d.mu.Unlock()
+
+	// handleReply calls Slack, it runs on the state copy without holding the lock
+	// and the state is reloaded from the file it saved on the next event.
+	action := handleReply(state, d.stateFile, d.slackToken, knownSuites, d.cfg, msg)
+
+	d.mu.Lock()
+	d.stateMod = time.Time{}
+	d.mu.Unlock()
+
+	if action == "" {
+		return
+	}
+	stats.commandHandled(action)
+
+	if action == actionRerun {
+		d.wakeRunner()
+	}
+}
+
+// dispatch queues a message event for processing, ignoring anything that is not a plain thread reply.
+func (d *daemon) dispatch(ev *slackEvent) {
+	if ev.Type != "message" || ev.Subtype != "" || ev.ThreadTS == "" || ev.BotID != "" {
+		return
+	}
+
+	select {
+	case d.events <- *ev:
+	default:
+		shared.LogLevel("warn", "Event buffer full - dropping event ts=%s, it will be picked up on restart", ev.TS)
+	}
+}
+
+// refreshState reloads the state file when it changed on disk, e.g. after a new run posted a new thread,
+// and returns a copy of it that can be used without holding the lock. It returns nil while there is no state file.
+func (d *daemon) refreshState() *rerunState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	mod := fileModTime(d.stateFile)
+	if mod.IsZero() {
+		d.state = nil
+		return nil
+	}
+	if d.state != nil && mod.Equal(d.stateMod) {
+		return d.stateCopy()
+	}
+
+	state, err := loadState(d.stateFile)
+	if err != nil {
+		shared.LogLevel("warn", "Failed to load state: %v", err)
+		return d.stateCopy()
+	}
+
+	if d.state == nil || d.state.ThreadTS != state.ThreadTS {
+		shared.LogLevel("info", "Tracking thread=%s, channel=%s, failed tests: %v",
+			state.ThreadTS, state.ChannelID, state.FailedTests)
+	}
+
+	d.state = state
+	d.stateMod = mod
+	d.knownSuites = loadKnownSuites(d.stateFile)
+
+	return d.stateCopy()
+}
+
+// stateCopy returns a copy of the loaded state, d.mu must be held.
+func (d *daemon) stateCopy() *rerunState {
+	if d.state == nil {
+		return nil
+	}
+
+	state := *d.state
+	state.FailedTests = slices.Clone(d.state.FailedTests)
+
+	return &state
+}
+
+// runRunner runs the queue whenever a rerun is queued, and periodically to pick up jobs queued by cron pollers.
+func (d *daemon) runRunner(ctx context.Context) {
+	ticker := time.NewTicker(runnerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+
+		if !acquireLock(runnerLockFile, "runner") {
+			continue
+		}
+		runQueue(ctx, filepath.Dir(d.stateFile), d.slackToken, d.cfg)
+		releaseLock(runnerLockFile, "runner")
+	}
+}
+
+func (d *daemon) wakeRunner() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *daemon) setConnected(connected bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.connected = connected
+}
+
+func (d *daemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.handleHealth)
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	if d.signingSecret != "" {
+		mux.HandleFunc("/slack/events", d.handleSlackEvents)
+	}
+
+	return mux
+}
+
+func (d *daemon) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	d.mu.Lock()
+	health := map[string]any{
+		"status":           "ok",
+		"state_loaded":     d.state != nil,
+		"socket_mode":      d.appToken != "",
+		"socket_connected": d.connected,
+		"events_api":       d.signingSecret != "",
+	}
+	if d.state != nil {
+		health["thread_ts"] = d.state.ThreadTS
+		health["last_processed_ts"] = d.state.LastProcessedTS
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if d.appToken != "" && !health["socket_connected"].(bool) {
+		health["status"] = "degraded"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(health)
+}
+
+func (d *daemon) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	var queued, running int
+	if err := withQueue(filepath.Dir(d.stateFile), func(q *rerunQueue, _ *rerunHistory) error {
+		queued, running = len(q.jobsIn(jobQueued)), len(q.jobsIn(jobRunning))
+		return nil
+	}); err != nil {
+		shared.LogLevel("warn", "Failed to read rerun queue for metrics: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	stats.write(w, queued, running)
+}
+
+// handleSlackEvents is the Events API request URL, verifying the Slack request signature.
+func (d *daemon) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventBodySize))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+
+	if !verifySlackSignature(d.signingSecret, r.Header, body, time.Now()) {
+		shared.LogLevel("warn", "Rejected Slack event with invalid signature from %s", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var cb slackEventCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		http.Error(w, "cannot parse event", http.StatusBadRequest)
+		return
+	}
+
+	switch cb.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(cb.Challenge))
+		return
+	case "event_callback":
+		d.dispatch(&cb.Event)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifySlackSignature checks the X-Slack-Signature header against the signing secret,
+// rejecting requests older than five minutes to prevent replays.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) bool {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	if age := now.Sub(time.Unix(sec, 0)); age > maxEventAge || age < -maxEventAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte("v0:" + ts + ":"))
+	_, _ = mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature")))
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testChannel = "C1"
+	testThread  = "1700000000.000100"
+)
+
+// newTestDaemon returns a daemon tracking the test thread, with its state and queue in a temp dir.
+func newTestDaemon(t *testing.T) *daemon {
+	t.Helper()
+
+	stateFile := filepath.Join(t.TempDir(), ".rerun-state.json")
+	state := &rerunState{
+		ChannelID:   testChannel,
+		ThreadTS:    testThread,
+		FailedTests: []string{"validatecluster"},
+		Product:     "rke2",
+	}
+	if err := saveState(stateFile, state); err != nil {
+		t.Fatalf("cannot save state: %v", err)
+	}
+
+	return &daemon{
+		slackToken: "xoxb-test",
+		stateFile:  stateFile,
+		cfg:        queueConfig{maxConcurrent: 1, userLimit: 3, userWindow: time.Hour},
+		events:     make(chan slackEvent, eventBufferSize),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// waitFor polls cond until it holds or the timeout expires.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForReply waits for the number of bot replies in the test thread to reach n and returns the last one.
+func waitForReply(t *testing.T, f *fakeSlackServer, n int) string {
+	t.Helper()
+
+	var replies []string
+	waitFor(t, "bot reply", func() bool {
+		replies = f.botReplies(testChannel, testThread)
+		return len(replies) >= n
+	})
+
+	return replies[n-1]
+}
+
+func TestDaemonSocketMode(t *testing.T) {
+	f := newFakeSlack(t)
+	d := newTestDaemon(t)
+	d.appToken = "xapp-test"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go d.runSocketMode(ctx)
+	d.processEvents(ctx)
+
+	waitFor(t, "socket mode connection", func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.connected
+	})
+
+	ts := f.inject(t, testChannel, testThread, "U1", "status")
+	if reply := waitForReply(t, f, 1); !strings.Contains(reply, "validatecluster") {
+		t.Fatalf("unexpected status reply %q", reply)
+	}
+	if acks := f.acked(); len(acks) != 1 || acks[0] != "env-"+ts {
+		t.Fatalf("expected envelope env-%s to be acknowledged, got %v", ts, acks)
+	}
+
+	f.inject(t, testChannel, "1600000000.000100", "U1", "status")
+	ts = f.inject(t, testChannel, testThread, "U1", "rerun: failed")
+	if reply := waitForReply(t, f, 2); !strings.Contains(reply, "Queued rerun #1") {
+		t.Fatalf("unexpected rerun reply %q", reply)
+	}
+	select {
+	case <-d.wake:
+	default:
+		t.Fatal("queued rerun did not wake the runner")
+	}
+
+	state, err := loadState(d.stateFile)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	if state.LastProcessedTS != ts {
+		t.Fatalf("expected last processed ts %s, got %s", ts, state.LastProcessedTS)
+	}
+	if replies := f.botReplies(testChannel, "1600000000.000100"); len(replies) != 0 {
+		t.Fatalf("replied in an untracked thread: %v", replies)
+	}
+
+	cancel()
+	waitFor(t, "socket mode disconnect", func() bool { return f.connections() == 0 })
+}
+
+func TestDaemonEventsAPI(t *testing.T) {
+	f := newFakeSlack(t)
+	d := newTestDaemon(t)
+	d.signingSecret = "test-secret"
+
+	srv := httptest.NewServer(d.routes())
+	t.Cleanup(srv.Close)
+	f.eventURL = srv.URL + "/slack/events"
+	f.secret = d.signingSecret
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	d.processEvents(ctx)
+
+	f.inject(t, testChannel, testThread, "U1", "help")
+	if reply := waitForReply(t, f, 1); reply != helpText {
+		t.Fatalf("unexpected help reply %q", reply)
+	}
+
+	challenge, err := json.Marshal(slackEventCallback{Type: "url_verification", Challenge: "abc"})
+	if err != nil {
+		t.Fatalf("cannot marshal challenge: %v", err)
+	}
+	if status := f.postEvent(t, challenge, time.Now()); status != http.StatusOK {
+		t.Fatalf("url verification returned %d", status)
+	}
+	if status := f.postEvent(t, challenge, time.Now().Add(-time.Hour)); status != http.StatusUnauthorized {
+		t.Fatalf("expected stale request to be rejected, got %d", status)
+	}
+
+	f.secret = "wrong-secret"
+	if status := f.postEvent(t, challenge, time.Now()); status != http.StatusUnauthorized {
+		t.Fatalf("expected request signed with another secret to be rejected, got %d", status)
+	}
+}
+
+// TestDaemonHealthDuringSlackCalls checks that the health endpoint answers while a reply waits on Slack.
+func TestDaemonHealthDuringSlackCalls(t *testing.T) {
+	f := newFakeSlack(t)
+	f.membersGate = make(chan struct{})
+	d := newTestDaemon(t)
+	d.signingSecret = "test-secret"
+
+	srv := httptest.NewServer(d.routes())
+	t.Cleanup(srv.Close)
+	f.eventURL = srv.URL + "/slack/events"
+	f.secret = d.signingSecret
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	d.processEvents(ctx)
+
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			close(f.membersGate)
+		}
+	}
+	t.Cleanup(release)
+
+	received := eventsReceived()
+	f.inject(t, testChannel, testThread, "U1", "status")
+	waitFor(t, "event to be handled", func() bool { return eventsReceived() > received })
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("health check blocked while the membership check was pending: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected health status %d", resp.StatusCode)
+	}
+
+	release()
+	waitForReply(t, f, 1)
+}
+
+func eventsReceived() int {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	return stats.eventsReceived
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	const secret = "test-secret"
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"event_callback"}`)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   bool
+	}{
+		{name: "valid", header: signedHeader(secret, now, body), body: body, want: true},
+		{name: "valid within clock skew", header: signedHeader(secret, now.Add(4*time.Minute), body), body: body, want: true},
+		{name: "wrong secret", header: signedHeader("other", now, body), body: body},
+		{name: "tampered body", header: signedHeader(secret, now, body), body: []byte(`{"type":"url_verification"}`)},
+		{name: "stale timestamp", header: signedHeader(secret, now.Add(-6*time.Minute), body), body: body},
+		{name: "future timestamp", header: signedHeader(secret, now.Add(6*time.Minute), body), body: body},
+		{name: "missing headers", header: http.Header{}, body: body},
+		{
+			name: "invalid timestamp",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": {"yesterday"},
+				"X-Slack-Signature":         signedHeader(secret, now, body)["X-Slack-Signature"],
+			},
+			body: body,
+		},
+		{
+			name: "missing version prefix",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": signedHeader(secret, now, body)["X-Slack-Request-Timestamp"],
+				"X-Slack-Signature": {
+					strings.TrimPrefix(signedHeader(secret, now, body).Get("X-Slack-Signature"), "v0="),
+				},
+			},
+			body: body,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySlackSignature(secret, tt.header, tt.body, now); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const fakeBotID = "B0FAKEBOT"
+
+// fakeSlackServer is a minimal in-memory Slack API, the poller and the daemon are pointed at it through slackAPIURL.
+// Replies added with inject are delivered over Socket Mode and, when eventURL is set, as signed Events API requests.
+type fakeSlackServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	seq      int
+	threads  map[string][]slackMessage
+	members  map[string]bool
+	sockets  map[*websocket.Conn]bool
+	acks     []string
+	eventURL string
+	secret   string
+
+	// membersGate, when set, blocks conversations.members until it is closed.
+	membersGate chan struct{}
+}
+
+// newFakeSlack starts the fake Slack API and points slackAPIURL at it for the duration of the test.
+func newFakeSlack(t *testing.T) *fakeSlackServer {
+	t.Helper()
+
+	f := &fakeSlackServer{
+		threads: make(map[string][]slackMessage),
+		members: make(map[string]bool),
+		sockets: make(map[*websocket.Conn]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth.test", f.handleOK)
+	mux.HandleFunc("/api/chat.postMessage", f.handlePostMessage)
+	mux.HandleFunc("/api/conversations.replies", f.handleReplies)
+	mux.HandleFunc("/api/conversations.members", f.handleMembers)
+	mux.HandleFunc("/api/apps.connections.open", f.handleConnectionsOpen)
+	mux.Handle("/socket", websocket.Handler(f.handleSocket))
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	apiURL := slackAPIURL
+	slackAPIURL = f.URL + "/api"
+	t.Cleanup(func() { slackAPIURL = apiURL })
+
+	return f
+}
+
+// add stores a message in its thread and returns its timestamp.
+func (f *fakeSlackServer) add(channel, threadTS string, msg slackMessage) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	msg.TS = fmt.Sprintf("%d.%06d", time.Now().Unix(), f.seq%1000000)
+	key := channel + "/" + threadTS
+	f.threads[key] = append(f.threads[key], msg)
+	if msg.User != "" {
+		f.members[msg.User] = true
+	}
+
+	return msg.TS
+}
+
+// inject adds a user reply to a thread and delivers it as an event, returning its timestamp.
+func (f *fakeSlackServer) inject(t *testing.T, channel, threadTS, user, text string) string {
+	t.Helper()
+
+	ev := slackEvent{Type: "message", Channel: channel, User: user, Text: text, ThreadTS: threadTS}
+	ev.TS = f.add(channel, threadTS, slackMessage{User: user, Text: text})
+
+	payload, err := json.Marshal(slackEventCallback{Type: "event_callback", Event: ev})
+	if err != nil {
+		t.Fatalf("cannot marshal event: %v", err)
+	}
+	f.pushSocket(t, socketEnvelope{Type: "events_api", EnvelopeID: "env-" + ev.TS, Payload: payload})
+
+	if f.eventURL != "" {
+		if status := f.postEvent(t, payload, time.Now()); status != http.StatusOK {
+			t.Fatalf("event delivery to %s returned %d", f.eventURL, status)
+		}
+	}
+
+	return ev.TS
+}
+
+// botReplies returns the text of the bot messages posted in the thread.
+func (f *fakeSlackServer) botReplies(channel, threadTS string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var replies []string
+	for _, msg := range f.threads[channel+"/"+threadTS] {
+		if msg.BotID != "" {
+			replies = append(replies, msg.Text)
+		}
+	}
+
+	return replies
+}
+
+func (f *fakeSlackServer) acked() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.acks...)
+}
+
+func (f *fakeSlackServer) connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.sockets)
+}
+
+func (f *fakeSlackServer) handleOK(w http.ResponseWriter, _ *http.Request) {
+	writeFakeJSON(w, map[string]any{"ok": true})
+}
+
+func (f *fakeSlackServer) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Channel  string `json:"channel"`
+		ThreadTS string `json:"thread_ts"`
+		Text     string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeJSON(w, map[string]any{"ok": false, "error": "invalid_json"})
+		return
+	}
+
+	ts := f.add(req.Channel, req.ThreadTS, slackMessage{BotID: fakeBotID, Text: req.Text})
+
+	writeFakeJSON(w, map[string]any{"ok": true, "channel": req.Channel, "ts": ts})
+}
+
+func (f *fakeSlackServer) handleReplies(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("channel") + "/" + r.URL.Query().Get("ts")
+
+	f.mu.Lock()
+	messages := append([]slackMessage{}, f.threads[key]...)
+	f.mu.Unlock()
+
+	writeFakeJSON(w, slackResponse{OK: true, Messages: messages})
+}
+
+// handleMembers reports every user that posted a reply as a channel member.
+func (f *fakeSlackServer) handleMembers(w http.ResponseWriter, _ *http.Request) {
+	if f.membersGate != nil {
+		<-f.membersGate
+	}
+
+	f.mu.Lock()
+	members := make([]string, 0, len(f.members))
+	for user := range f.members {
+		members = append(members, user)
+	}
+	f.mu.Unlock()
+
+	writeFakeJSON(w, map[string]any{"ok": true, "members": members})
+}
+
+func (f *fakeSlackServer) handleConnectionsOpen(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, map[string]any{"ok": true, "url": "ws://" + r.Host + "/socket"})
+}
+
+// handleSocket sends hello and records the acknowledgements until the client goes away.
+func (f *fakeSlackServer) handleSocket(conn *websocket.Conn) {
+	f.mu.Lock()
+	f.sockets[conn] = true
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.sockets, conn)
+		f.mu.Unlock()
+	}()
+
+	if err := websocket.JSON.Send(conn, socketEnvelope{Type: "hello"}); err != nil {
+		return
+	}
+
+	for {
+		var ack map[string]string
+		if err := websocket.JSON.Receive(conn, &ack); err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.acks = append(f.acks, ack["envelope_id"])
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeSlackServer) pushSocket(t *testing.T, env socketEnvelope) {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for conn := range f.sockets {
+		if err := websocket.JSON.Send(conn, env); err != nil {
+			t.Errorf("cannot push event: %v", err)
+		}
+	}
+}
+
+// postEvent delivers the payload to the Events API URL signed like Slack does at ts, returning the status code.
+func (f *fakeSlackServer) postEvent(t *testing.T, payload []byte, ts time.Time) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, f.eventURL, strings.NewReader(string(payload)))
+	if err != nil {
+		t.Fatalf("cannot create request: %v", err)
+	}
+	req.Header = signedHeader(f.secret, ts, payload)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// signedHeader returns the Slack request signature headers for body sent at ts.
+func signedHeader(secret string, ts time.Time, body []byte) http.Header {
+	sec := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte("v0:" + sec + ":"))
+	_, _ = mac.Write(body)
+
+	header := http.Header{}
+	header.Set("X-Slack-Request-Timestamp", sec)
+	header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+
+	return header
+}
+
+func writeFakeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
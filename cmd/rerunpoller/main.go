@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -30,12 +32,14 @@ type rerunState struct {
 	FailedTests     []string `json:"failed_tests"`
 	Product         string   `json:"product"`
 	PostedAt        string   `json:"posted_at"`
+	QaseRunID       int32    `json:"qase_run_id,omitempty"`
 }
 
 type slackMessage struct {
-	TS   string `json:"ts"`
-	User string `json:"user"`
-	Text string `json:"text"`
+	TS    string `json:"ts"`
+	User  string `json:"user"`
+	Text  string `json:"text"`
+	BotID string `json:"bot_id,omitempty"`
 }
 
 type slackResponse struct {
@@ -44,28 +48,59 @@ type slackResponse struct {
 	Messages []slackMessage `json:"messages"`
 }
 
+// slackAPIURL is the Slack Web API base URL, overridable with SLACK_API_URL.
+var slackAPIURL = "https://slack.com/api"
+
+// errThreadReplaced is returned when a state update targets a thread the state file no longer tracks.
+var errThreadReplaced = errors.New("a new run replaced the rerun thread")
+
+var (
+	daemonMode bool
+	listenAddr string
+)
+
 func main() {
+	flag.BoolVar(&daemonMode, "daemon", false, "run as a long-running daemon receiving Slack events instead of polling")
+	flag.StringVar(&listenAddr, "addr", ":8080", "daemon listen address for the health, metrics and Slack events endpoints")
+	flag.Parse()
+
+	if url := os.Getenv("SLACK_API_URL"); url != "" {
+		slackAPIURL = strings.TrimSuffix(url, "/")
+	}
+
+	if daemonMode {
+		if err := runDaemon(listenAddr); err != nil {
+			shared.LogLevel("error", "Rerun daemon failed: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	shared.LogLevel("info", "\nRerun Poller Starting\n")
 
-	if !acquirePollerLock() {
+	if !acquireLock(pollLockFile, "poller") {
 		os.Exit(0)
 	}
-	defer releasePollerLock()
+	defer releaseLock(pollLockFile, "poller")
 
-	if running, reason := isTestsRunning(); running {
-		shared.LogLevel("info", "Tests already running (%s) - skipping this poll cycle", reason)
-		return //nolint:gocritic // intentional exit.
+	state, stateFile, slackToken := initializePoller()
+	queueDir := filepath.Dir(stateFile)
+	cfg := loadQueueConfig()
+
+	if err := processThreadReplies(state, stateFile, slackToken, loadKnownSuites(stateFile), cfg); err != nil {
+		shared.LogLevel("error", "Failed to process thread replies: %v", err)
+		os.Exit(1) //nolint:gocritic // intentional exit.
 	}
 
-	state, stateFile, slackToken := initializePoller()
-	rerunMsg, rerunTests := findRerunRequest(state, slackToken)
+	// release the poller lock before running the queue so later polls can still enqueue and answer commands.
+	releaseLock(pollLockFile, "poller")
 
-	if rerunMsg == nil {
-		shared.LogLevel("info", "No rerun request found in new messages - nothing to do")
+	if !acquireLock(runnerLockFile, "runner") {
 		return
 	}
+	defer releaseLock(runnerLockFile, "runner")
 
-	processRerunRequest(state, stateFile, slackToken, rerunMsg, rerunTests)
+	runQueue(context.Background(), queueDir, slackToken, cfg)
 }
 
 func getHomeDir() string {
@@ -105,142 +140,210 @@ func initializePoller() (state *rerunState, stateFile, slackToken string) {
 	return state, stateFile, slackToken
 }
 
-func findRerunRequest(state *rerunState, slackToken string) (rerunMsg *slackMessage, rerunTests string) {
+// processThreadReplies fetches the thread replies and handles the new ones from oldest to newest.
+func processThreadReplies(
+	state *rerunState,
+	stateFile, slackToken string,
+	knownSuites []string,
+	cfg queueConfig,
+) error {
 	shared.LogLevel("info", "Fetching replies from Slack...")
 	replies, err := fetchSlackReplies(slackToken, state.ChannelID, state.ThreadTS)
 	if err != nil {
-		shared.LogLevel("error", "Failed to fetch Slack replies: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to fetch Slack replies: %w", err)
 	}
 	shared.LogLevel("info", "Fetched %d messages from thread", len(replies.Messages))
 
-	for i := len(replies.Messages) - 1; i >= 0; i-- {
-		msg := replies.Messages[i]
+	for i := range replies.Messages {
+		handleReply(state, stateFile, slackToken, knownSuites, cfg, &replies.Messages[i])
+	}
 
-		if msg.TS == state.ThreadTS {
-			continue
-		}
+	return nil
+}
 
-		if msg.TS <= state.LastProcessedTS {
-			continue
-		}
+// handleReply answers a single thread reply in-thread.
+// Rerun requests are added to the queue, status, cancel and help are answered right away.
+// It returns the handled command action, or an empty string if the message was ignored.
+func handleReply(
+	state *rerunState,
+	stateFile, slackToken string,
+	knownSuites []string,
+	cfg queueConfig,
+	msg *slackMessage,
+) string {
+	if msg.TS == state.ThreadTS || msg.TS <= state.LastProcessedTS || msg.BotID != "" {
+		return ""
+	}
 
-		tests := parseRerunRequest(msg.Text)
-		if tests != "" {
-			isMember, err := isChannelMember(slackToken, state.ChannelID, msg.User)
-			if err != nil {
-				shared.LogLevel("warn", "Failed to check channel membership for user %s: %v", msg.User, err)
-				continue
-			}
-			if !isMember {
-				shared.LogLevel("warn", "Unauthorized rerun request from user %s (not a channel member)", msg.User)
-				continue
-			}
+	cmd, parseErr := parseRerunRequest(msg.Text, state, knownSuites)
+	if cmd == nil && parseErr == nil {
+		shared.LogLevel("info", "Skipping non-command message: ts=%s, text='%s'", msg.TS, msg.Text)
+		return ""
+	}
 
-			rerunMsg = &msg
-			rerunTests = tests
-			shared.LogLevel("info", "Found rerun request: ts=%s, user=%s, tests='%s'", msg.TS, msg.User, tests)
+	isMember, err := isChannelMember(slackToken, state.ChannelID, msg.User)
+	if err != nil {
+		shared.LogLevel("warn", "Failed to check channel membership for user %s: %v", msg.User, err)
+		return ""
+	}
+	if !isMember {
+		shared.LogLevel("warn", "Unauthorized command from user %s (not a channel member)", msg.User)
+		return ""
+	}
 
-			break
-		}
-		shared.LogLevel("info", "Skipping non-rerun message: ts=%s, text='%s'", msg.TS, msg.Text)
+	if parseErr != nil {
+		shared.LogLevel("warn", "Invalid command from user %s: %v", msg.User, parseErr)
+		replyAndMarkProcessed(state, stateFile, slackToken, msg.TS,
+			fmt.Sprintf(":warning: <@%s> %v\nReply with `help` to see the available commands.", msg.User, parseErr))
+
+		return "invalid"
+	}
+
+	queueDir := filepath.Dir(stateFile)
+
+	var reply string
+	switch cmd.action {
+	case actionStatus:
+		reply = statusMessage(state, queueDir)
+	case actionHelp:
+		reply = helpText
+	case actionCancel:
+		reply = cancelMessage(queueDir, msg.User)
+	case actionRerun:
+		reply = enqueueMessage(queueDir, state, cmd, msg, cfg)
 	}
+	replyAndMarkProcessed(state, stateFile, slackToken, msg.TS, reply)
 
-	return rerunMsg, rerunTests
+	return cmd.action
 }
 
-func processRerunRequest(state *rerunState, stateFile, slackToken string, rerunMsg *slackMessage, rerunTests string) {
-	//  add timestamp BEFORE executing to prevent duplicate triggers.
-	oldTS := state.LastProcessedTS
-	state.LastProcessedTS = rerunMsg.TS
-	if err := saveState(stateFile, state); err != nil {
-		shared.LogLevel("error", "CRITICAL: Failed to save state: %v", err)
-		shared.LogLevel("error", "Aborting to prevent duplicate triggers")
-		os.Exit(1)
+// replyAndMarkProcessed posts a reply in the thread and moves last_processed_ts past the message.
+func replyAndMarkProcessed(state *rerunState, stateFile, slackToken, ts, text string) {
+	markProcessed(state, stateFile, ts)
+
+	if err := postToSlack(slackToken, state.ChannelID, state.ThreadTS, text); err != nil {
+		shared.LogLevel("warn", "Failed to post reply to Slack: %v", err)
 	}
-	shared.LogLevel("info", "SAFETY: Updated last_processed_ts from %s to %s BEFORE processing", oldTS, rerunMsg.TS)
+}
 
-	// map "failed" to actual test names.
-	if rerunTests == "failed" {
-		if len(state.FailedTests) == 0 {
-			shared.LogLevel("warn", "User requested 'rerun: failed' but no failed tests recorded")
-			_ = postToSlack(slackToken, state.ChannelID, state.ThreadTS,
-				":warning: No failed tests recorded from the last run.")
-			os.Exit(0)
+// markProcessed saves the message timestamp as processed, aborting if the state can not be saved
+// to prevent duplicate triggers. The state is re-read from the file, so failed tests refreshed
+// by a finished rerun are kept and picked up by the in-memory state.
+func markProcessed(state *rerunState, stateFile, ts string) {
+	oldTS := state.LastProcessedTS
+	state.LastProcessedTS = ts
+
+	err := withState(stateFile, func(saved *rerunState) error {
+		if saved.ThreadTS != state.ThreadTS {
+			return fmt.Errorf("%w: tracking %s instead of %s", errThreadReplaced, saved.ThreadTS, state.ThreadTS)
 		}
-		rerunTests = strings.Join(state.FailedTests, ",")
-		shared.LogLevel("info", "Resolved 'failed' to: %s", rerunTests)
-	}
 
-	// check tests aren't running now.
-	if running, reason := isTestsRunning(); running {
-		shared.LogLevel("warn", "Tests started running between checks (%s) - aborting", reason)
-		os.Exit(0)
-	}
+		if ts > saved.LastProcessedTS {
+			saved.LastProcessedTS = ts
+		}
+		state.FailedTests = saved.FailedTests
 
-	if err := executeRerun(rerunTests, slackToken, state.ChannelID, state.ThreadTS, rerunMsg.User); err != nil {
-		shared.LogLevel("error", "Rerun failed: %v", err)
+		return nil
+	})
+	if errors.Is(err, errThreadReplaced) {
+		shared.LogLevel("info", "Not marking ts=%s as processed: %v", ts, err)
+		return
+	}
+	if err != nil {
+		shared.LogLevel("error", "CRITICAL: Failed to save state: %v", err)
+		shared.LogLevel("error", "Aborting to prevent duplicate triggers")
 		os.Exit(1)
 	}
+	shared.LogLevel("info", "SAFETY: Updated last_processed_ts from %s to %s", oldTS, ts)
 }
 
-func acquirePollerLock() bool {
-	if data, err := os.ReadFile(pollLockFile); err == nil {
+// acquireLock creates a PID lock file, replacing it if the owning process is gone.
+func acquireLock(path, name string) bool {
+	if data, err := os.ReadFile(path); err == nil {
 		pidStr := strings.TrimSpace(string(data))
 		if pid, err := strconv.Atoi(pidStr); err == nil && pid > 0 {
 			if processExists(pid) {
-				shared.LogLevel("info", "Another poller instance running (PID %d) - exiting", pid)
+				shared.LogLevel("info", "Another %s instance running (PID %d) - exiting", name, pid)
 				return false
 			}
 		}
-		shared.LogLevel("warn", "Removing stale poller lock (PID was %s)", pidStr)
-		_ = os.Remove(pollLockFile)
+		shared.LogLevel("warn", "Removing stale %s lock (PID was %s)", name, pidStr)
+		_ = os.Remove(path)
 	}
 
-	if err := os.WriteFile(pollLockFile, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
-		shared.LogLevel("error", "Failed to create poller lock: %v", err)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+		shared.LogLevel("error", "Failed to create %s lock: %v", name, err)
 		return false
 	}
-	shared.LogLevel("info", "Acquired poller lock (PID %d)", os.Getpid())
+	shared.LogLevel("info", "Acquired %s lock (PID %d)", name, os.Getpid())
 
 	return true
 }
 
-func releasePollerLock() {
-	_ = os.Remove(pollLockFile)
-	shared.LogLevel("info", "Released poller lock")
+// releaseLock removes the lock file if this process still owns it.
+func releaseLock(path, name string) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+
+	_ = os.Remove(path)
+	shared.LogLevel("info", "Released %s lock", name)
 }
 
 func isTestsRunning() (running bool, reason string) {
-	if data, err := os.ReadFile(lockFile); err == nil {
-		pidStr := strings.TrimSpace(string(data))
-		if pid, err := strconv.Atoi(pidStr); err == nil && pid > 0 {
-			if processExists(pid) {
-				return true, fmt.Sprintf("lock file PID %d is alive", pid)
-			}
-			shared.LogLevel("warn", "Stale test lock file found (PID %d dead), removing", pid)
-			_ = os.Remove(lockFile)
-		}
+	if pids := lockFilePIDs(); len(pids) > 0 {
+		return true, fmt.Sprintf("lock file PID %d is alive", pids[0])
 	}
 
-	if out, _ := exec.Command("pgrep", "-f", "go test.*_test.go").Output(); len(out) > 0 {
-		pids := strings.TrimSpace(string(out))
-		return true, "go test processes running: " + pids
+	if pids := pgrepPIDs("go test.*_test.go"); len(pids) > 0 {
+		return true, fmt.Sprintf("go test processes running: %v", pids)
 	}
 
-	if out, _ := exec.Command("pgrep", "-f", "run_tests.sh").Output(); len(out) > 0 {
-		pids := strings.TrimSpace(string(out))
-		return true, "run_tests.sh running: " + pids
+	if pids := pgrepPIDs("run_tests.sh"); len(pids) > 0 {
+		return true, fmt.Sprintf("run_tests.sh running: %v", pids)
 	}
 
-	if out, _ := exec.Command("pgrep", "-f", "vagrant up").Output(); len(out) > 0 {
-		pids := strings.TrimSpace(string(out))
-		return true, "vagrant up running: " + pids
+	if pids := pgrepPIDs("vagrant up"); len(pids) > 0 {
+		return true, fmt.Sprintf("vagrant up running: %v", pids)
 	}
 
 	return false, ""
 }
 
+// lockFilePIDs returns the PID from the tests lock file if that process is alive, removing stale lock files.
+func lockFilePIDs() []int {
+	data, err := os.ReadFile(lockFile)
+	if err != nil {
+		return nil
+	}
+
+	pidStr := strings.TrimSpace(string(data))
+	if pid, err := strconv.Atoi(pidStr); err == nil && pid > 0 {
+		if processExists(pid) {
+			return []int{pid}
+		}
+		shared.LogLevel("warn", "Stale test lock file found (PID %d dead), removing", pid)
+		_ = os.Remove(lockFile)
+	}
+
+	return nil
+}
+
+// pgrepPIDs returns the PIDs of the processes matching the pattern.
+func pgrepPIDs(pattern string) []int {
+	out, _ := exec.Command("pgrep", "-f", pattern).Output()
+
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids
+}
+
 func processExists(pid int) bool {
 	proc, err := os.FindProcess(pid)
 	if err != nil {
@@ -272,6 +375,27 @@ func loadState(stateFile string) (*rerunState, error) {
 	return &state, nil
 }
 
+// withState loads the state file under the dir lock, calls fn and saves the state back.
+// Every state write goes through it so concurrent writers never overwrite each other with a stale state.
+func withState(stateFile string, fn func(state *rerunState) error) error {
+	unlock, err := lockDir(filepath.Dir(stateFile))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	return saveState(stateFile, state)
+}
+
 func saveState(stateFile string, state *rerunState) error {
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
@@ -291,7 +415,7 @@ func saveState(stateFile string, state *rerunState) error {
 }
 
 func fetchSlackReplies(token, channelID, threadTS string) (*slackResponse, error) {
-	url := fmt.Sprintf("https://slack.com/api/conversations.replies?channel=%s&ts=%s", channelID, threadTS)
+	url := fmt.Sprintf("%s/conversations.replies?channel=%s&ts=%s", slackAPIURL, channelID, threadTS)
 
 	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
 	if err != nil {
@@ -330,7 +454,7 @@ func isChannelMember(token, channelID, userID string) (bool, error) {
 	cursor := ""
 
 	for {
-		url := fmt.Sprintf("https://slack.com/api/conversations.members?channel=%s&limit=1000", channelID)
+		url := fmt.Sprintf("%s/conversations.members?channel=%s&limit=1000", slackAPIURL, channelID)
 		if cursor != "" {
 			url += "&cursor=" + cursor
 		}
@@ -391,7 +515,7 @@ func postToSlack(token, channelID, threadTS, text string) error {
 	}
 	data, _ := json.Marshal(payload)
 
-	req, err := http.NewRequest(http.MethodPost, "https://slack.com/api/chat.postMessage", bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPost, slackAPIURL+"/chat.postMessage", bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("cannot create request: %w", err)
 	}
@@ -424,67 +548,3 @@ func postToSlack(token, channelID, threadTS, text string) error {
 
 	return nil
 }
-
-func parseRerunRequest(text string) string {
-	text = strings.TrimSpace(text)
-	lower := strings.ToLower(text)
-
-	if !strings.HasPrefix(lower, "rerun:") {
-		return ""
-	}
-
-	tests := strings.TrimSpace(text[6:])
-	tests = strings.ToLower(tests)
-
-	if tests == "failed" {
-		return tests
-	}
-
-	return ""
-}
-
-func executeRerun(tests, slackToken, channelID, threadTS, user string) error {
-	shared.LogLevel("info", "\nSTARTING RERUN\n")
-	shared.LogLevel("info", "  Tests: %s", tests)
-	shared.LogLevel("info", "  Requested by: %s", user)
-	shared.LogLevel("info", "  Thread: %s\n", threadTS)
-
-	msg := fmt.Sprintf(":arrows_counterclockwise: Starting rerun of: `%s`\nRequested by: <@%s>", tests, user)
-	if err := postToSlack(slackToken, channelID, threadTS, msg); err != nil {
-		shared.LogLevel("warn", "Failed to post start message to Slack: %v", err)
-	}
-
-	runTestsScript := filepath.Join(getHomeDir(), "run_tests.sh")
-	shared.LogLevel("info", "Using run_tests.sh at: %s", runTestsScript)
-
-	if _, err := os.Stat(runTestsScript); os.IsNotExist(err) {
-		return fmt.Errorf("run_tests.sh not found at %s", runTestsScript)
-	}
-
-	cmd := exec.Command(runTestsScript, "-test", tests)
-	cmd.Env = append(os.Environ(), "IS_RERUN=true")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	startTime := time.Now()
-	err := cmd.Run()
-	duration := time.Since(startTime)
-
-	if err != nil {
-		exitCode := 1
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		}
-		shared.LogLevel("error", "Rerun FAILED after %v (exit code %d)", duration, exitCode)
-
-		msg := fmt.Sprintf(":x: Rerun failed for: `%s`\nExit code: %d\nDuration: %v",
-			tests, exitCode, duration.Round(time.Second))
-		_ = postToSlack(slackToken, channelID, threadTS, msg)
-
-		return fmt.Errorf("tests failed with exit code %d", exitCode)
-	}
-
-	shared.LogLevel("info", "Rerun COMPLETED successfully in %v", duration.Round(time.Second))
-
-	return nil
-}
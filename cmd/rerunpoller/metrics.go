@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// stats holds the daemon counters exposed on the metrics endpoint.
+var stats = newMetrics()
+
+type metrics struct {
+	mu               sync.Mutex
+	startedAt        time.Time
+	eventsReceived   int
+	commands         map[string]int
+	jobsFinished     map[string]int
+	socketReconnects int
+	lastEventAt      time.Time
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		startedAt:    time.Now(),
+		commands:     make(map[string]int),
+		jobsFinished: make(map[string]int),
+	}
+}
+
+func (m *metrics) eventReceived() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.eventsReceived++
+	m.lastEventAt = time.Now()
+}
+
+func (m *metrics) commandHandled(action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commands[action]++
+}
+
+func (m *metrics) jobFinished(state string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobsFinished[state]++
+}
+
+func (m *metrics) socketReconnected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.socketReconnects++
+}
+
+// write renders the counters in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer, queued, running int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# TYPE rerunpoller_uptime_seconds gauge\nrerunpoller_uptime_seconds %.0f\n",
+		time.Since(m.startedAt).Seconds())
+	_, _ = fmt.Fprintf(w, "# TYPE rerunpoller_events_received_total counter\nrerunpoller_events_received_total %d\n",
+		m.eventsReceived)
+	if !m.lastEventAt.IsZero() {
+		_, _ = fmt.Fprintf(w, "# TYPE rerunpoller_last_event_timestamp_seconds gauge\n"+
+			"rerunpoller_last_event_timestamp_seconds %d\n", m.lastEventAt.Unix())
+	}
+	_, _ = fmt.Fprintf(w, "# TYPE rerunpoller_socket_reconnects_total counter\nrerunpoller_socket_reconnects_total %d\n",
+		m.socketReconnects)
+
+	_, _ = fmt.Fprint(w, "# TYPE rerunpoller_commands_total counter\n")
+	for _, action := range sortedKeys(m.commands) {
+		_, _ = fmt.Fprintf(w, "rerunpoller_commands_total{action=%q} %d\n", action, m.commands[action])
+	}
+
+	_, _ = fmt.Fprint(w, "# TYPE rerunpoller_jobs_finished_total counter\n")
+	for _, state := range sortedKeys(m.jobsFinished) {
+		_, _ = fmt.Fprintf(w, "rerunpoller_jobs_finished_total{state=%q} %d\n", state, m.jobsFinished[state])
+	}
+
+	_, _ = fmt.Fprintf(w, "# TYPE rerunpoller_jobs gauge\nrerunpoller_jobs{state=%q} %d\nrerunpoller_jobs{state=%q} %d\n",
+		jobQueued, queued, jobRunning, running)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
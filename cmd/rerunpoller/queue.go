@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+
+	queueFileName   = ".rerun-queue.json"
+	historyFileName = ".rerun-history.json"
+	queueLockName   = ".rerun-queue.lock"
+
+	defaultMaxConcurrent = 1
+	defaultUserLimit     = 3
+	defaultUserWindow    = time.Hour
+	maxHistoryEntries    = 200
+)
+
+// errNothingToCancel is returned by cancel when no rerun is queued or running.
+var errNothingToCancel = errors.New("no reruns are queued or running")
+
+// rerunJob is a single rerun request tracked through the queue and the history.
+type rerunJob struct {
+	ID         int        `json:"id"`
+	Tests      []string   `json:"tests"`
+	Arch       string     `json:"arch,omitempty"`
+	Product    string     `json:"product,omitempty"`
+	User       string     `json:"user"`
+	ChannelID  string     `json:"channel_id"`
+	ThreadTS   string     `json:"thread_ts"`
+	RequestTS  string     `json:"request_ts"`
+	State      string     `json:"state"`
+	PID        int        `json:"pid,omitempty"`
+	ExitCode   int        `json:"exit_code"`
+	Error      string     `json:"error,omitempty"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// LogFile is the go test JSON log run_tests.sh writes for this job, set when the job starts.
+	LogFile string `json:"log_file,omitempty"`
+
+	// CancelledBy is set on a running job signalled by cancel, it is recorded as cancelled once its process exits.
+	CancelledBy string `json:"cancelled_by,omitempty"`
+
+	// PreviousFailed and QaseRunID are taken from the rerun state when queued, to compare the rerun results with.
+	PreviousFailed []string `json:"previous_failed,omitempty"`
+	QaseRunID      int32    `json:"qase_run_id,omitempty"`
+}
+
+// rerunQueue holds the queued and running jobs, persisted next to the rerun state file.
+type rerunQueue struct {
+	NextID int         `json:"next_id"`
+	Jobs   []*rerunJob `json:"jobs"`
+}
+
+// rerunHistory holds the finished jobs, newest last.
+type rerunHistory struct {
+	Jobs []*rerunJob `json:"jobs"`
+}
+
+// queueConfig holds the queue limits, read from the environment.
+type queueConfig struct {
+	maxConcurrent int
+	userLimit     int
+	userWindow    time.Duration
+}
+
+// loadQueueConfig reads RERUN_MAX_CONCURRENT, RERUN_USER_LIMIT and RERUN_USER_WINDOW,
+// falling back to one concurrent run and three requests per user per hour.
+func loadQueueConfig() queueConfig {
+	cfg := queueConfig{
+		maxConcurrent: defaultMaxConcurrent,
+		userLimit:     defaultUserLimit,
+		userWindow:    defaultUserWindow,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("RERUN_MAX_CONCURRENT")); err == nil && v > 0 {
+		cfg.maxConcurrent = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("RERUN_USER_LIMIT")); err == nil && v > 0 {
+		cfg.userLimit = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("RERUN_USER_WINDOW")); err == nil && v > 0 {
+		cfg.userWindow = v
+	}
+
+	return cfg
+}
+
+// lockDir takes the exclusive file lock guarding the queue, history and state files in dir.
+// The Qase reporter takes the same lock before writing a new state file.
+func lockDir(dir string) (unlock func(), err error) {
+	lock, err := os.OpenFile(filepath.Join(dir, queueLockName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open queue lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("cannot lock queue: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		_ = lock.Close()
+	}, nil
+}
+
+// withQueue loads the queue and history under the dir lock, calls fn and saves both back.
+func withQueue(dir string, fn func(q *rerunQueue, h *rerunHistory) error) error {
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	q := &rerunQueue{NextID: 1}
+	if err := readJSONFile(filepath.Join(dir, queueFileName), q); err != nil {
+		return err
+	}
+	h := &rerunHistory{}
+	if err := readJSONFile(filepath.Join(dir, historyFileName), h); err != nil {
+		return err
+	}
+
+	if err := fn(q, h); err != nil {
+		return err
+	}
+
+	if len(h.Jobs) > maxHistoryEntries {
+		h.Jobs = h.Jobs[len(h.Jobs)-maxHistoryEntries:]
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, queueFileName), q); err != nil {
+		return err
+	}
+
+	return writeJSONFile(filepath.Join(dir, historyFileName), h)
+}
+
+// enqueue adds a rerun request to the queue, rejecting duplicates of queued or running jobs
+// and users above their rate limit.
+func (q *rerunQueue) enqueue(
+	h *rerunHistory,
+	state *rerunState,
+	cmd *rerunCommand,
+	msg *slackMessage,
+	cfg queueConfig,
+) (*rerunJob, error) {
+	for _, job := range q.Jobs {
+		if slices.Equal(job.Tests, cmd.tests) && job.Arch == cmd.arch {
+			return nil, fmt.Errorf("the same rerun is already %s as job #%d", job.State, job.ID)
+		}
+	}
+
+	since := time.Now().Add(-cfg.userWindow)
+	count := 0
+	for _, job := range slices.Concat(q.Jobs, h.Jobs) {
+		if job.User == msg.User && job.QueuedAt.After(since) {
+			count++
+		}
+	}
+	if count >= cfg.userLimit {
+		return nil, fmt.Errorf("rate limit reached: %d reruns per %v per user", cfg.userLimit, cfg.userWindow)
+	}
+
+	job := &rerunJob{
+		ID:        q.NextID,
+		Tests:     cmd.tests,
+		Arch:      cmd.arch,
+		Product:   state.Product,
+		User:      msg.User,
+		ChannelID: state.ChannelID,
+		ThreadTS:  state.ThreadTS,
+		RequestTS: msg.TS,
+		State:     jobQueued,
+		QueuedAt:  time.Now(),
+
+		PreviousFailed: slices.Clone(state.FailedTests),
+		QaseRunID:      state.QaseRunID,
+	}
+	q.NextID++
+	q.Jobs = append(q.Jobs, job)
+
+	return job, nil
+}
+
+// jobsIn returns the jobs in the given state, in queue order.
+func (q *rerunQueue) jobsIn(state string) []*rerunJob {
+	var jobs []*rerunJob
+	for _, job := range q.Jobs {
+		if job.State == state {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// find returns the job with the given ID from the queue.
+func (q *rerunQueue) find(id int) *rerunJob {
+	for _, job := range q.Jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// finish moves a job from the queue to the history with its final state.
+func (q *rerunQueue) finish(h *rerunHistory, id int, state string, exitCode int, errMsg string) *rerunJob {
+	for i, job := range q.Jobs {
+		if job.ID != id {
+			continue
+		}
+
+		now := time.Now()
+		job.State = state
+		job.ExitCode = exitCode
+		job.Error = errMsg
+		job.FinishedAt = &now
+
+		q.Jobs = slices.Delete(q.Jobs, i, i+1)
+		h.Jobs = append(h.Jobs, job)
+
+		return job
+	}
+
+	return nil
+}
+
+// reconcile fails running jobs whose process is gone without this runner tracking them,
+// which happens when a previous runner was killed. Cancelled jobs are recorded as cancelled.
+func (q *rerunQueue) reconcile(h *rerunHistory, tracked map[int]bool) []*rerunJob {
+	var lost []*rerunJob
+	for _, job := range q.jobsIn(jobRunning) {
+		if tracked[job.ID] || (job.PID > 0 && processExists(job.PID)) {
+			continue
+		}
+		if job.CancelledBy != "" {
+			lost = append(lost, q.finish(h, job.ID, jobCancelled, -1, "cancelled by "+job.CancelledBy))
+			continue
+		}
+		lost = append(lost, q.finish(h, job.ID, jobFailed, -1, "runner exited before the job finished"))
+	}
+
+	return lost
+}
+
+// cancel moves every queued job to the history as cancelled and signals the running ones,
+// returning a description of what was done.
+func (q *rerunQueue) cancel(h *rerunHistory, user string) (string, error) {
+	queued := q.jobsIn(jobQueued)
+	running := q.jobsIn(jobRunning)
+	if len(queued) == 0 && len(running) == 0 {
+		return "", errNothingToCancel
+	}
+
+	for _, job := range queued {
+		q.finish(h, job.ID, jobCancelled, -1, "cancelled by "+user)
+	}
+
+	var signalled []string
+	for _, job := range running {
+		// the runner signals a job without PID yet once it records it.
+		// run_tests.sh runs in its own process group, signal the whole group.
+		if job.PID > 0 {
+			if err := syscall.Kill(-job.PID, syscall.SIGTERM); err != nil {
+				shared.LogLevel("warn", "Failed to stop job #%d (PID %d): %v", job.ID, job.PID, err)
+				continue
+			}
+		}
+		job.CancelledBy = user
+		signalled = append(signalled, fmt.Sprintf("#%d", job.ID))
+	}
+
+	return fmt.Sprintf("dropped %d queued job(s), stopping running job(s): %s",
+		len(queued), strings.Join(signalled, ", ")), nil
+}
+
+// summary describes the queue and the recent history for the thread replies.
+func (q *rerunQueue) summary(h *rerunHistory) string {
+	var sb strings.Builder
+
+	running := q.jobsIn(jobRunning)
+	queued := q.jobsIn(jobQueued)
+	sb.WriteString(fmt.Sprintf("*Queue:* %d running, %d queued\n", len(running), len(queued)))
+	for _, job := range slices.Concat(running, queued) {
+		sb.WriteString("• " + job.describe() + "\n")
+	}
+
+	counts := make(map[string]int)
+	for _, job := range h.Jobs {
+		counts[job.State]++
+	}
+	sb.WriteString(fmt.Sprintf("*History:* %d reruns, %d done, %d failed, %d cancelled\n",
+		len(h.Jobs), counts[jobDone], counts[jobFailed], counts[jobCancelled]))
+
+	recent := h.Jobs[max(0, len(h.Jobs)-5):]
+	for i := len(recent) - 1; i >= 0; i-- {
+		sb.WriteString("• " + recent[i].describe() + "\n")
+	}
+
+	return sb.String()
+}
+
+// describe returns a single line description of the job.
+func (j *rerunJob) describe() string {
+	desc := fmt.Sprintf("#%d `%s`", j.ID, strings.Join(j.Tests, ","))
+	if j.Arch != "" {
+		desc += " (" + j.Arch + ")"
+	}
+	desc += fmt.Sprintf(" by <@%s> - %s", j.User, j.State)
+
+	switch {
+	case j.FinishedAt != nil && j.StartedAt != nil:
+		desc += fmt.Sprintf(" in %v", j.FinishedAt.Sub(*j.StartedAt).Round(time.Second))
+	case j.StartedAt != nil:
+		desc += fmt.Sprintf(" for %v", time.Since(*j.StartedAt).Round(time.Second))
+	}
+	if j.Error != "" {
+		desc += " (" + j.Error + ")"
+	}
+
+	return desc
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s: %w", path, err)
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+		return fmt.Errorf("cannot write temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		_ = os.Remove(tmpFile)
+		return fmt.Errorf("cannot rename %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnqueue(t *testing.T) {
+	cfg := queueConfig{userLimit: 2, userWindow: time.Hour}
+	state := &rerunState{
+		ChannelID:   "C1",
+		ThreadTS:    "100.1",
+		Product:     "rke2",
+		FailedTests: []string{"validatecluster"},
+		QaseRunID:   42,
+	}
+	recent := time.Now().Add(-10 * time.Minute)
+	old := time.Now().Add(-2 * time.Hour)
+
+	tests := []struct {
+		name    string
+		queue   []*rerunJob
+		history []*rerunJob
+		cmd     *rerunCommand
+		user    string
+		wantErr string
+	}{
+		{
+			name: "empty queue",
+			cmd:  &rerunCommand{tests: []string{"validatecluster"}},
+			user: "U1",
+		},
+		{
+			name:    "duplicate of a queued job",
+			queue:   []*rerunJob{{ID: 1, Tests: []string{"validatecluster"}, State: jobQueued, User: "U2"}},
+			cmd:     &rerunCommand{tests: []string{"validatecluster"}},
+			user:    "U1",
+			wantErr: "already queued as job #1",
+		},
+		{
+			name:    "duplicate of a running job",
+			queue:   []*rerunJob{{ID: 3, Tests: []string{"certrotate"}, Arch: "arm64", State: jobRunning, User: "U2"}},
+			cmd:     &rerunCommand{tests: []string{"certrotate"}, arch: "arm64"},
+			user:    "U1",
+			wantErr: "already running as job #3",
+		},
+		{
+			name:  "same tests on another arch",
+			queue: []*rerunJob{{ID: 3, Tests: []string{"certrotate"}, Arch: "arm64", State: jobRunning, User: "U2"}},
+			cmd:   &rerunCommand{tests: []string{"certrotate"}, arch: "amd64"},
+			user:  "U1",
+		},
+		{
+			name:    "finished jobs are not duplicates",
+			history: []*rerunJob{{ID: 1, Tests: []string{"validatecluster"}, State: jobDone, User: "U2", QueuedAt: recent}},
+			cmd:     &rerunCommand{tests: []string{"validatecluster"}},
+			user:    "U1",
+		},
+		{
+			name:  "rate limit counts queued and finished jobs",
+			queue: []*rerunJob{{ID: 2, Tests: []string{"a"}, State: jobQueued, User: "U1", QueuedAt: recent}},
+			history: []*rerunJob{
+				{ID: 1, Tests: []string{"b"}, State: jobCancelled, User: "U1", QueuedAt: recent},
+			},
+			cmd:     &rerunCommand{tests: []string{"validatecluster"}},
+			user:    "U1",
+			wantErr: "rate limit reached",
+		},
+		{
+			name: "rate limit ignores jobs outside the window and of other users",
+			history: []*rerunJob{
+				{ID: 1, Tests: []string{"a"}, State: jobDone, User: "U1", QueuedAt: old},
+				{ID: 2, Tests: []string{"b"}, State: jobDone, User: "U2", QueuedAt: recent},
+				{ID: 3, Tests: []string{"c"}, State: jobDone, User: "U1", QueuedAt: recent},
+			},
+			cmd:  &rerunCommand{tests: []string{"validatecluster"}},
+			user: "U1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &rerunQueue{NextID: 7, Jobs: tt.queue}
+			h := &rerunHistory{Jobs: tt.history}
+			queued := len(q.Jobs)
+
+			job, err := q.enqueue(h, state, tt.cmd, &slackMessage{User: tt.user, TS: "200.1"}, cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if len(q.Jobs) != queued || q.NextID != 7 {
+					t.Fatalf("rejected request changed the queue: %d jobs, next id %d", len(q.Jobs), q.NextID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if job.ID != 7 || q.NextID != 8 || q.Jobs[len(q.Jobs)-1] != job {
+				t.Fatalf("job #%d not appended with the next id, next id %d", job.ID, q.NextID)
+			}
+			if job.State != jobQueued || job.User != tt.user || job.RequestTS != "200.1" || job.Arch != tt.cmd.arch ||
+				!slices.Equal(job.Tests, tt.cmd.tests) {
+				t.Fatalf("unexpected job %+v", job)
+			}
+			if job.ChannelID != "C1" || job.ThreadTS != "100.1" || job.Product != "rke2" || job.QaseRunID != 42 ||
+				!slices.Equal(job.PreviousFailed, state.FailedTests) {
+				t.Fatalf("job %+v does not carry the state of the run", job)
+			}
+		})
+	}
+}
+
+func TestEnqueueKeepsPreviousFailed(t *testing.T) {
+	state := &rerunState{FailedTests: []string{"validatecluster"}}
+	q := &rerunQueue{NextID: 1}
+
+	job, err := q.enqueue(&rerunHistory{}, state, &rerunCommand{tests: []string{"validatecluster"}},
+		&slackMessage{User: "U1"}, queueConfig{userLimit: 1, userWindow: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state.FailedTests[0] = "certrotate"
+	if job.PreviousFailed[0] != "validatecluster" {
+		t.Fatalf("previous failed tests share the state slice: %v", job.PreviousFailed)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name          string
+		jobs          []*rerunJob
+		wantErr       error
+		wantCancelled []int
+		wantSignalled []int
+	}{
+		{name: "empty queue", wantErr: errNothingToCancel},
+		{
+			name: "queued jobs",
+			jobs: []*rerunJob{
+				{ID: 1, State: jobQueued},
+				{ID: 2, State: jobQueued},
+			},
+			wantCancelled: []int{1, 2},
+		},
+		{
+			name: "running job without pid",
+			jobs: []*rerunJob{
+				{ID: 1, State: jobRunning},
+				{ID: 2, State: jobQueued},
+			},
+			wantCancelled: []int{2},
+			wantSignalled: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &rerunQueue{Jobs: tt.jobs}
+			h := &rerunHistory{}
+
+			res, err := q.cancel(h, "U1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var cancelled []int
+			for _, job := range h.Jobs {
+				if job.State != jobCancelled || job.Error != "cancelled by U1" || job.FinishedAt == nil {
+					t.Fatalf("unexpected history job %+v", job)
+				}
+				cancelled = append(cancelled, job.ID)
+			}
+			if !slices.Equal(cancelled, tt.wantCancelled) {
+				t.Fatalf("expected cancelled %v, got %v", tt.wantCancelled, cancelled)
+			}
+
+			var signalled []int
+			for _, job := range q.Jobs {
+				if job.State != jobRunning || job.CancelledBy != "U1" {
+					t.Fatalf("unexpected queue job %+v", job)
+				}
+				signalled = append(signalled, job.ID)
+			}
+			if !slices.Equal(signalled, tt.wantSignalled) {
+				t.Fatalf("expected signalled %v, got %v", tt.wantSignalled, signalled)
+			}
+			if !strings.Contains(res, "dropped "+strconv.Itoa(len(tt.wantCancelled))+" queued job(s)") {
+				t.Fatalf("unexpected cancel reply %q", res)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	deadPID := exitedPID(t)
+
+	q := &rerunQueue{Jobs: []*rerunJob{
+		{ID: 1, State: jobRunning, PID: deadPID},
+		{ID: 2, State: jobRunning, PID: deadPID},
+		{ID: 3, State: jobRunning, PID: os.Getpid()},
+		{ID: 4, State: jobRunning, PID: deadPID, CancelledBy: "U1"},
+		{ID: 5, State: jobRunning},
+		{ID: 6, State: jobQueued},
+	}}
+	h := &rerunHistory{}
+
+	lost := q.reconcile(h, map[int]bool{2: true})
+
+	got := make(map[int]string)
+	for _, job := range lost {
+		got[job.ID] = job.State
+	}
+	want := map[int]string{1: jobFailed, 4: jobCancelled, 5: jobFailed}
+	if len(got) != len(want) {
+		t.Fatalf("expected lost jobs %v, got %v", want, got)
+	}
+	for id, state := range want {
+		if got[id] != state {
+			t.Fatalf("expected job #%d %s, got %q", id, state, got[id])
+		}
+	}
+
+	var remaining []int
+	for _, job := range q.Jobs {
+		remaining = append(remaining, job.ID)
+	}
+	if !slices.Equal(remaining, []int{2, 3, 6}) {
+		t.Fatalf("expected tracked, alive and queued jobs to stay queued, got %v", remaining)
+	}
+	if len(h.Jobs) != 3 {
+		t.Fatalf("expected lost jobs in the history, got %d", len(h.Jobs))
+	}
+}
+
+func TestSummaryCountsCancelled(t *testing.T) {
+	h := &rerunHistory{Jobs: []*rerunJob{
+		{ID: 1, State: jobDone},
+		{ID: 2, State: jobFailed},
+		{ID: 3, State: jobCancelled},
+		{ID: 4, State: jobCancelled},
+	}}
+
+	summary := (&rerunQueue{}).summary(h)
+	if !strings.Contains(summary, "4 reruns, 1 done, 1 failed, 2 cancelled") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+}
+
+func TestLoadQueueConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent string
+		want          int
+	}{
+		{name: "default", want: defaultMaxConcurrent},
+		{name: "configured", maxConcurrent: "3", want: 3},
+		{name: "zero falls back to default", maxConcurrent: "0", want: defaultMaxConcurrent},
+		{name: "invalid falls back to default", maxConcurrent: "two", want: defaultMaxConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RERUN_MAX_CONCURRENT", tt.maxConcurrent)
+			if got := loadQueueConfig().maxConcurrent; got != tt.want {
+				t.Errorf("maxConcurrent = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// exitedPID returns the PID of a process that already exited.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("cannot run true: %v", err)
+	}
+
+	return cmd.Process.Pid
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/pkg/qase"
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+// reportRerunResults compares the rerun log with the failed tests the job was queued against,
+// posts the diff in the job thread, adds the results to the original Qase run
+// and refreshes the state file so the next `rerun: failed` targets only the remaining failures.
+func reportRerunResults(dir, slackToken string, job *rerunJob) {
+	logFile := job.LogFile
+	if _, err := os.Stat(logFile); err != nil {
+		err = fmt.Errorf("run_tests.sh did not write the rerun log %s: %w", logFile, err)
+		shared.LogLevel("warn", "Rerun #%d results not compared: %v", job.ID, err)
+		postRerunResult(slackToken, job, fmt.Sprintf(":warning: Rerun #%d results not compared: %v", job.ID, err))
+		return
+	}
+
+	diff, err := qase.DiffRerun(logFile, job.Product, job.Arch, filepath.Dir(dir), job.PreviousFailed, job.Tests)
+	if err != nil {
+		shared.LogLevel("warn", "Rerun #%d results not compared: %v", job.ID, err)
+		postRerunResult(slackToken, job, fmt.Sprintf(":warning: Rerun #%d results not compared: %v", job.ID, err))
+		return
+	}
+
+	shared.LogLevel("info", "Rerun #%d: now passing %v, still failing %v, newly failing %v",
+		job.ID, diff.NowPassing, diff.StillFailing, diff.NewlyFailing)
+
+	msg := rerunDiffMessage(job, diff)
+	if qaseMsg := addQaseResults(logFile, job); qaseMsg != "" {
+		msg += "\n" + qaseMsg
+	}
+
+	if err := refreshFailedTests(filepath.Join(dir, ".rerun-state.json"), job.ThreadTS, diff.Remaining); err != nil {
+		shared.LogLevel("warn", "Failed to refresh failed tests after rerun #%d: %v", job.ID, err)
+	}
+
+	postRerunResult(slackToken, job, msg)
+}
+
+// rerunDiffMessage builds the Slack reply comparing the rerun with the original run.
+func rerunDiffMessage(job *rerunJob, diff *qase.RerunDiff) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(":bar_chart: *Rerun #%d compared with the original run*\n", job.ID))
+	sb.WriteString(diffLine(":white_check_mark: Now passing", diff.NowPassing))
+	sb.WriteString(diffLine(":x: Still failing", diff.StillFailing))
+	sb.WriteString(diffLine(":new: Newly failing", diff.NewlyFailing))
+	if len(diff.NoResults) > 0 {
+		sb.WriteString(diffLine(":grey_question: No results", diff.NoResults))
+	}
+
+	if len(diff.Remaining) == 0 {
+		sb.WriteString("\n:tada: No failures left from the original run")
+	} else {
+		sb.WriteString(fmt.Sprintf("\nRemaining failures: `%s` - reply `rerun: failed` to rerun them",
+			strings.Join(diff.Remaining, "`, `")))
+	}
+
+	return sb.String()
+}
+
+func diffLine(title string, tests []string) string {
+	if len(tests) == 0 {
+		return fmt.Sprintf("%s (0)\n", title)
+	}
+
+	return fmt.Sprintf("%s (%d): `%s`\n", title, len(tests), strings.Join(tests, "`, `"))
+}
+
+// addQaseResults adds the rerun results to the original Qase run and returns a note for the Slack reply.
+func addQaseResults(logFile string, job *rerunJob) string {
+	if job.QaseRunID <= 0 {
+		return ""
+	}
+
+	qaseClient, err := qase.AddQase()
+	if err != nil {
+		shared.LogLevel("warn", "Qase client not configured: %v - skipping Qase update", err)
+		return ""
+	}
+
+	if err := qaseClient.AddRunResults(logFile, job.Product, job.Arch, job.QaseRunID); err != nil {
+		shared.LogLevel("warn", "Failed to update Qase run %d: %v", job.QaseRunID, err)
+		return fmt.Sprintf(":warning: Failed to update Qase run %d", job.QaseRunID)
+	}
+
+	return fmt.Sprintf("Qase run %d updated with the rerun results", job.QaseRunID)
+}
+
+// refreshFailedTests saves the remaining failures in the state file,
+// unless a new run replaced the thread the rerun belongs to.
+func refreshFailedTests(stateFile, threadTS string, remaining []string) error {
+	err := withState(stateFile, func(state *rerunState) error {
+		if state.ThreadTS != threadTS {
+			return fmt.Errorf("%w: tracking %s instead of %s", errThreadReplaced, state.ThreadTS, threadTS)
+		}
+
+		state.FailedTests = remaining
+
+		return nil
+	})
+	if errors.Is(err, errThreadReplaced) {
+		shared.LogLevel("info", "Not updating failed tests: %v", err)
+		return nil
+	}
+
+	return err
+}
+
+func postRerunResult(slackToken string, job *rerunJob, msg string) {
+	if err := postToSlack(slackToken, job.ChannelID, job.ThreadTS, msg); err != nil {
+		shared.LogLevel("warn", "Failed to post rerun results to Slack: %v", err)
+	}
+}
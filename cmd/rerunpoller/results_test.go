@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStateWrites(t *testing.T) {
+	tests := []struct {
+		name        string
+		threadTS    string
+		wantFailed  []string
+		wantLastTS  string
+		wantUpdated bool
+	}{
+		{
+			name:        "same thread keeps the refreshed failures",
+			threadTS:    testThread,
+			wantFailed:  []string{"certrotate"},
+			wantLastTS:  "1700000000.000300",
+			wantUpdated: true,
+		},
+		{
+			name:       "replaced thread is left untouched",
+			threadTS:   "1600000000.000100",
+			wantFailed: []string{"certrotate", "validatecluster"},
+			wantLastTS: testThread,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), ".rerun-state.json")
+			if err := saveState(stateFile, &rerunState{
+				ChannelID:       testChannel,
+				ThreadTS:        testThread,
+				LastProcessedTS: testThread,
+				FailedTests:     []string{"certrotate", "validatecluster"},
+			}); err != nil {
+				t.Fatalf("cannot save state: %v", err)
+			}
+
+			// the poller loaded the state before the rerun refreshed the failures.
+			stale, err := loadState(stateFile)
+			if err != nil {
+				t.Fatalf("cannot load state: %v", err)
+			}
+			stale.ThreadTS = tt.threadTS
+
+			if err := refreshFailedTests(stateFile, tt.threadTS, []string{"certrotate"}); err != nil {
+				t.Fatalf("cannot refresh failed tests: %v", err)
+			}
+			markProcessed(stale, stateFile, "1700000000.000300")
+
+			saved, err := loadState(stateFile)
+			if err != nil {
+				t.Fatalf("cannot load state: %v", err)
+			}
+			if !slices.Equal(saved.FailedTests, tt.wantFailed) || saved.LastProcessedTS != tt.wantLastTS {
+				t.Fatalf("unexpected saved state %+v", saved)
+			}
+			if tt.wantUpdated && !slices.Equal(stale.FailedTests, tt.wantFailed) {
+				t.Fatalf("in-memory failures not refreshed: %v", stale.FailedTests)
+			}
+		})
+	}
+}
+
+func TestMarkProcessedNeverMovesBack(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), ".rerun-state.json")
+	if err := saveState(stateFile, &rerunState{
+		ChannelID:       testChannel,
+		ThreadTS:        testThread,
+		LastProcessedTS: "1700000000.000500",
+	}); err != nil {
+		t.Fatalf("cannot save state: %v", err)
+	}
+
+	markProcessed(&rerunState{ChannelID: testChannel, ThreadTS: testThread}, stateFile, "1700000000.000300")
+
+	saved, err := loadState(stateFile)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	if saved.LastProcessedTS != "1700000000.000500" {
+		t.Fatalf("last processed ts moved back to %s", saved.LastProcessedTS)
+	}
+}
+
+func TestRerunLogFile(t *testing.T) {
+	tests := []struct {
+		job  *rerunJob
+		want string
+	}{
+		{job: &rerunJob{ID: 3, Product: "rke2"}, want: "/report/rke2_rerun-3.log"},
+		{job: &rerunJob{ID: 12, Product: "k3s"}, want: "/report/k3s_rerun-12.log"},
+		{job: &rerunJob{ID: 4}, want: "/report/rerun-4.log"},
+	}
+
+	for _, tt := range tests {
+		if got := rerunLogFile("/report", tt.job); got != tt.want {
+			t.Errorf("job #%d: expected %s, got %s", tt.job.ID, tt.want, got)
+		}
+	}
+}
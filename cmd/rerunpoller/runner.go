@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	runnerLockFile = "/tmp/rerun-runner.lock"
+	runnerTick     = 30 * time.Second
+)
+
+// jobResult is sent by a finished job to the runner loop.
+type jobResult struct {
+	id       int
+	exitCode int
+	duration time.Duration
+	err      error
+}
+
+// runQueue starts queued jobs up to the concurrency cap and waits for them to finish,
+// picking up jobs enqueued by later polls, until the queue is empty or ctx is cancelled.
+// When no rerun is running, jobs are only started when no other tests hold the tests lock or are running on the host.
+// Jobs still running when ctx is cancelled keep running and are reconciled by the next runner.
+//
+//nolint:funlen // runner loop is easier to follow in one place.
+func runQueue(ctx context.Context, dir, slackToken string, cfg queueConfig) {
+	results := make(chan jobResult, cfg.maxConcurrent)
+	tracked := make(map[int]bool)
+
+	for {
+		if ctx.Err() != nil {
+			shared.LogLevel("info", "Runner stopping, leaving %d running job(s) to the next runner", len(tracked))
+			return
+		}
+
+		var (
+			toStart []*rerunJob
+			lost    []*rerunJob
+			idle    bool
+		)
+
+		err := withQueue(dir, func(q *rerunQueue, h *rerunHistory) error {
+			lost = q.reconcile(h, tracked)
+
+			running := len(q.jobsIn(jobRunning))
+			queued := q.jobsIn(jobQueued)
+			idle = running == 0 && len(queued) == 0
+
+			if running >= cfg.maxConcurrent || len(queued) == 0 {
+				return nil
+			}
+
+			// running reruns hold the tests lock themselves, only tests started outside the queue block the first one.
+			if running == 0 {
+				if external, reason := isTestsRunning(); external {
+					shared.LogLevel("info", "Tests already running (%s) - waiting before starting queued reruns", reason)
+					return nil
+				}
+			}
+
+			for _, job := range queued {
+				if running >= cfg.maxConcurrent {
+					break
+				}
+				now := time.Now()
+				job.State = jobRunning
+				job.StartedAt = &now
+				job.LogFile = rerunLogFile(dir, job)
+				toStart = append(toStart, job)
+				running++
+			}
+
+			return nil
+		})
+		if err != nil {
+			shared.LogLevel("error", "Failed to update rerun queue: %v", err)
+			return
+		}
+
+		for _, job := range lost {
+			postJobSummary(dir, slackToken, job)
+		}
+
+		for _, job := range toStart {
+			cmd, startErr := startRerun(job, slackToken)
+			if startErr != nil {
+				shared.LogLevel("error", "Failed to start job #%d: %v", job.ID, startErr)
+				finishJob(dir, slackToken, jobResult{id: job.ID, exitCode: -1, err: startErr})
+				continue
+			}
+
+			tracked[job.ID] = true
+			if err := withQueue(dir, func(q *rerunQueue, _ *rerunHistory) error {
+				j := q.find(job.ID)
+				if j == nil {
+					return nil
+				}
+				j.PID = cmd.Process.Pid
+				if j.CancelledBy != "" {
+					// cancelled while starting, before cancel could signal it.
+					_ = syscall.Kill(-j.PID, syscall.SIGTERM)
+				}
+				return nil
+			}); err != nil {
+				shared.LogLevel("warn", "Failed to record PID for job #%d: %v", job.ID, err)
+			}
+
+			go waitRerun(job, cmd, results)
+		}
+
+		if idle && len(tracked) == 0 {
+			shared.LogLevel("info", "Rerun queue is empty - runner exiting")
+			return
+		}
+
+		select {
+		case res := <-results:
+			delete(tracked, res.id)
+			finishJob(dir, slackToken, res)
+		case <-time.After(runnerTick):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// startRerun posts the start message and launches run_tests.sh for the job in its own process group.
+func startRerun(job *rerunJob, slackToken string) (*exec.Cmd, error) {
+	tests := strings.Join(job.Tests, ",")
+
+	shared.LogLevel("info", "\nSTARTING RERUN #%d\n", job.ID)
+	shared.LogLevel("info", "  Tests: %s", tests)
+	shared.LogLevel("info", "  Arch: %s", job.Arch)
+	shared.LogLevel("info", "  Requested by: %s", job.User)
+	shared.LogLevel("info", "  Thread: %s\n", job.ThreadTS)
+
+	runTestsScript := filepath.Join(getHomeDir(), "run_tests.sh")
+	shared.LogLevel("info", "Using run_tests.sh at: %s", runTestsScript)
+
+	if _, err := os.Stat(runTestsScript); os.IsNotExist(err) {
+		return nil, fmt.Errorf("run_tests.sh not found at %s", runTestsScript)
+	}
+
+	args := []string{"-test", tests}
+	if job.Arch != "" {
+		args = append(args, "-arch", job.Arch)
+	}
+
+	// a log left by an earlier queue with the same job ID must not be compared with this rerun.
+	if err := os.Remove(job.LogFile); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot remove stale log %s: %w", job.LogFile, err)
+	}
+
+	cmd := exec.Command(runTestsScript, args...)
+	cmd.Env = append(os.Environ(), "IS_RERUN=true", fmt.Sprintf("RERUN_ID=%d", job.ID), "RERUN_LOG_FILE="+job.LogFile)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start run_tests.sh: %w", err)
+	}
+
+	msg := fmt.Sprintf(":arrows_counterclockwise: Starting rerun #%d of: `%s`\nRequested by: <@%s>",
+		job.ID, tests, job.User)
+	if job.Arch != "" {
+		msg += "\nArch: " + job.Arch
+	}
+	if err := postToSlack(slackToken, job.ChannelID, job.ThreadTS, msg); err != nil {
+		shared.LogLevel("warn", "Failed to post start message to Slack: %v", err)
+	}
+
+	return cmd, nil
+}
+
+// rerunLogFile returns the log path passed to run_tests.sh for the job, named like the product logs
+// of the report dir so the other report tooling still picks it up.
+func rerunLogFile(dir string, job *rerunJob) string {
+	name := fmt.Sprintf("rerun-%d.log", job.ID)
+	if job.Product != "" {
+		name = job.Product + "_" + name
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// waitRerun waits for the job process and reports its exit code.
+func waitRerun(job *rerunJob, cmd *exec.Cmd, results chan<- jobResult) {
+	err := cmd.Wait()
+
+	res := jobResult{id: job.ID, duration: time.Since(*job.StartedAt)}
+	if err != nil {
+		res.exitCode = 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			res.exitCode = exitErr.ExitCode()
+		}
+		res.err = fmt.Errorf("tests failed with exit code %d", res.exitCode)
+	}
+
+	results <- res
+}
+
+// finishJob moves the job to the history and posts the summary in its thread.
+func finishJob(dir, slackToken string, res jobResult) {
+	state, errMsg := jobDone, ""
+	if res.err != nil {
+		state, errMsg = jobFailed, res.err.Error()
+		shared.LogLevel("error", "Rerun #%d FAILED after %v: %v", res.id, res.duration.Round(time.Second), res.err)
+	} else {
+		shared.LogLevel("info", "Rerun #%d COMPLETED successfully in %v", res.id, res.duration.Round(time.Second))
+	}
+
+	var job *rerunJob
+	if err := withQueue(dir, func(q *rerunQueue, h *rerunHistory) error {
+		if j := q.find(res.id); j != nil && j.CancelledBy != "" {
+			state, errMsg = jobCancelled, "cancelled by "+j.CancelledBy
+		}
+		job = q.finish(h, res.id, state, res.exitCode, errMsg)
+		return nil
+	}); err != nil {
+		shared.LogLevel("error", "Failed to record result of job #%d: %v", res.id, err)
+		return
+	}
+
+	if job != nil {
+		stats.jobFinished(job.State)
+		postJobSummary(dir, slackToken, job)
+		if job.State != jobCancelled && job.StartedAt != nil && res.exitCode >= 0 {
+			reportRerunResults(dir, slackToken, job)
+		}
+	}
+}
+
+// postJobSummary posts the job outcome together with the queue and history summary in the job thread.
+func postJobSummary(dir, slackToken string, job *rerunJob) {
+	emoji := ":white_check_mark:"
+	switch job.State {
+	case jobFailed:
+		emoji = ":x:"
+	case jobCancelled:
+		emoji = ":octagonal_sign:"
+	}
+
+	msg := fmt.Sprintf("%s Rerun #%d %s: `%s`\nExit code: %d\n", emoji, job.ID, job.State,
+		strings.Join(job.Tests, ","), job.ExitCode)
+	if job.StartedAt != nil && job.FinishedAt != nil {
+		msg += fmt.Sprintf("Duration: %v\n", job.FinishedAt.Sub(*job.StartedAt).Round(time.Second))
+	}
+	if job.Error != "" {
+		msg += "Error: " + job.Error + "\n"
+	}
+
+	if err := withQueue(dir, func(q *rerunQueue, h *rerunHistory) error {
+		msg += "\n" + q.summary(h)
+		return nil
+	}); err != nil {
+		shared.LogLevel("warn", "Failed to read rerun queue for summary: %v", err)
+	}
+
+	if err := postToSlack(slackToken, job.ChannelID, job.ThreadTS, msg); err != nil {
+		shared.LogLevel("warn", "Failed to post rerun summary to Slack: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	socketRetryDelay    = 5 * time.Second
+	maxSocketRetryDelay = 2 * time.Minute
+)
+
+// socketEnvelope is a Socket Mode message, events are acknowledged by sending back the envelope ID.
+type socketEnvelope struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// runSocketMode keeps a Socket Mode connection open until ctx is cancelled,
+// reconnecting with backoff when Slack or the network drops it.
+func (d *daemon) runSocketMode(ctx context.Context) {
+	delay := socketRetryDelay
+
+	for {
+		started := time.Now()
+		err := d.socketSession(ctx)
+		d.setConnected(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// a session that stayed up for a while is a normal refresh, reconnect right away.
+		if time.Since(started) > maxSocketRetryDelay {
+			delay = socketRetryDelay
+		}
+
+		shared.LogLevel("warn", "Socket Mode connection closed: %v - reconnecting in %v", err, delay)
+		stats.socketReconnected()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxSocketRetryDelay)
+	}
+}
+
+// socketSession opens a Socket Mode connection and dispatches events until it is closed.
+func (d *daemon) socketSession(ctx context.Context) error {
+	wsURL, err := openSocketConnection(d.appToken)
+	if err != nil {
+		return err
+	}
+
+	conn, err := websocket.Dial(wsURL, "", slackAPIURL)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", wsURL, err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	for {
+		var env socketEnvelope
+		if err := websocket.JSON.Receive(conn, &env); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("cannot receive message: %w", err)
+		}
+
+		if env.EnvelopeID != "" {
+			if err := websocket.JSON.Send(conn, map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return fmt.Errorf("cannot acknowledge envelope %s: %w", env.EnvelopeID, err)
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			shared.LogLevel("info", "Socket Mode connected")
+			d.setConnected(true)
+		case "disconnect":
+			return fmt.Errorf("disconnect requested by Slack: %s", env.Reason)
+		case "events_api":
+			var cb slackEventCallback
+			if err := json.Unmarshal(env.Payload, &cb); err != nil {
+				shared.LogLevel("warn", "Cannot parse Socket Mode event: %v", err)
+				continue
+			}
+			d.dispatch(&cb.Event)
+		}
+	}
+}
+
+// openSocketConnection requests a Socket Mode WebSocket URL with the app-level token.
+func openSocketConnection(appToken string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, slackAPIURL+"/apps.connections.open", http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+appToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read response: %w", err)
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error,omitempty"`
+		URL   string `json:"url"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("cannot parse response: %w", err)
+	}
+
+	if !result.OK {
+		return "", fmt.Errorf("slack API error: %s", result.Error)
+	}
+	if result.URL == "" {
+		return "", errors.New("slack API returned no Socket Mode URL")
+	}
+
+	return result.URL, nil
+}
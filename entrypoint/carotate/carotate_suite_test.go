@@ -0,0 +1,94 @@
+package carotate
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rancher/distros-test-framework/config"
+	"github.com/rancher/distros-test-framework/pkg/aws"
+	"github.com/rancher/distros-test-framework/pkg/customflag"
+	"github.com/rancher/distros-test-framework/pkg/qase"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	qaseReport    = os.Getenv("REPORT_TO_QASE")
+	flags         *customflag.FlagConfig
+	kubeconfig    string
+	cluster       *shared.Cluster
+	cfg           *config.Env
+	reportSummary string
+	reportErr     error
+	err           error
+	awsClient     *aws.Client
+)
+
+func TestMain(m *testing.M) {
+	flags = &customflag.ServiceFlag
+	flag.Var(&flags.Destroy, "destroy", "Destroy cluster after test")
+	flag.BoolVar(&flags.CertificateReport, "certReport", false,
+		"Attach the certificate inventory of every node to the report summary")
+	flag.Parse()
+
+	cfg, err = config.AddEnv()
+	if err != nil {
+		shared.LogLevel("error", "error adding env vars: %w\n", err)
+		os.Exit(1)
+	}
+
+	kubeconfig = os.Getenv("KUBE_CONFIG")
+	if kubeconfig == "" {
+		// gets a cluster from terraform.
+		cluster = shared.ClusterConfig(cfg)
+	} else {
+		// gets a cluster from kubeconfig.
+		cluster = shared.KubeConfigCluster(kubeconfig)
+	}
+
+	awsClient, err = aws.AddClient(cluster)
+	if err != nil {
+		shared.LogLevel("error", "error adding aws nodes: %s", err)
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestCARotateSuite(t *testing.T) {
+	RegisterFailHandler(FailWithReport)
+	RunSpecs(t, "CA Rotate Test Suite")
+}
+
+var _ = ReportAfterSuite("CA Rotate Test Suite", func(report Report) {
+	// Add Qase reporting capabilities.
+	if strings.ToLower(qaseReport) == "true" {
+		qaseClient, err := qase.AddQase()
+		Expect(err).ToNot(HaveOccurred(), "error adding qase")
+
+		qaseClient.SpecReportTestResults(qaseClient.Ctx, cluster, &report, reportSummary)
+	} else {
+		shared.LogLevel("info", "Qase reporting is not enabled")
+	}
+})
+
+var _ = AfterSuite(func() {
+	reportSummary, reportErr = shared.SummaryReportData(cluster, flags)
+	if reportErr != nil {
+		shared.LogLevel("error", "error getting report summary data: %v\n", reportErr)
+	}
+
+	if customflag.ServiceFlag.Destroy {
+		status, err := shared.DestroyCluster(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal("cluster destroyed"))
+	}
+})
+
+func FailWithReport(message string, callerSkip ...int) {
+	Fail(message, callerSkip[0]+1)
+}
@@ -0,0 +1,114 @@
+package carotate
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/testcase"
+)
+
+var _ = Describe("Test:", func() {
+	var persistence *testcase.PersistenceData
+
+	It("Start Up with no issues", func() {
+		testcase.TestBuildCluster(cluster)
+	})
+
+	It("Validate Nodes", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pods", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady(),
+		)
+	})
+
+	It("Verifies ClusterIP Service before CA rotation", func() {
+		testcase.TestServiceClusterIP(true, false)
+	})
+
+	It("Verifies Daemonset before CA rotation", func() {
+		testcase.TestDaemonset(true, false)
+	})
+
+	It("Seeds persistence data", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
+	It("Validate rotation to custom CA certificates", func() {
+		testcase.TestCARotate(cluster)
+	})
+
+	It("Validate Nodes after rotation to custom CA", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pods after rotation to custom CA", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady(),
+		)
+	})
+
+	It("Verifies ClusterIP Service after rotation to custom CA", func() {
+		testcase.TestServiceClusterIP(false, false)
+	})
+
+	It("Validate rotation of the custom CA certificates", func() {
+		testcase.TestCARotate(cluster)
+	})
+
+	It("Validate Nodes after custom CA rotation", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pods after custom CA rotation", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady(),
+		)
+	})
+
+	It("Verifies ClusterIP Service after custom CA rotation", func() {
+		testcase.TestServiceClusterIP(false, true)
+	})
+
+	It("Verifies Daemonset after custom CA rotation", func() {
+		testcase.TestDaemonset(false, true)
+	})
+
+	It("Validates data persistence after CA rotation", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
+	It("Validate bootstrap from custom CA certificates and their rotation", func() {
+		testcase.TestCABootstrap(cluster, awsClient, cfg)
+	})
+})
+
+var _ = AfterEach(func() {
+	if CurrentSpecReport().Failed() {
+		fmt.Printf("\nFAILED! %s\n\n", CurrentSpecReport().FullText())
+	} else {
+		fmt.Printf("\nPASSED! %s\n\n", CurrentSpecReport().FullText())
+	}
+})
@@ -29,6 +29,8 @@ var (
 func TestMain(m *testing.M) {
 	flags = &customflag.ServiceFlag
 	flag.Var(&flags.Destroy, "destroy", "Destroy cluster after test")
+	flag.BoolVar(&flags.CertificateReport, "certReport", false,
+		"Attach the certificate inventory of every node to the report summary")
 	flag.Parse()
 
 	cfg, err = config.AddEnv()
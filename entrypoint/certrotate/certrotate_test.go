@@ -10,6 +10,8 @@ import (
 )
 
 var _ = Describe("Test:", func() {
+	var persistence *testcase.PersistenceData
+
 	It("Start Up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -30,6 +32,16 @@ var _ = Describe("Test:", func() {
 		)
 	})
 
+	if cluster.Config.SplitRoles.Enabled {
+		It("Validate Node Roles", func() {
+			testcase.TestNodeRoles(cluster)
+		})
+	}
+
+	It("Seeds persistence data", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
 	It("Validate Certificate Rotation", func() {
 		testcase.TestCertRotate(cluster)
 	})
@@ -49,6 +61,10 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady(),
 		)
 	})
+
+	It("Validates data persistence after certificate rotation", func() {
+		testcase.TestDataPersistence(persistence)
+	})
 })
 
 var _ = AfterEach(func() {
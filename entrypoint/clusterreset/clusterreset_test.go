@@ -4,12 +4,18 @@ import (
 	"fmt"
 
 	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
 	"github.com/rancher/distros-test-framework/pkg/testcase"
 
 	. "github.com/onsi/ginkgo/v2"
 )
 
 var _ = Describe("Test:", func() {
+	var (
+		persistence *testcase.PersistenceData
+		snapshot    *k8s.ClusterSnapshot
+	)
+
 	It("Start Up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -33,6 +39,14 @@ var _ = Describe("Test:", func() {
 		testcase.TestServiceClusterIP(true, true)
 	})
 
+	It("Takes a cluster state snapshot", func() {
+		snapshot = testcase.SnapshotClusterState()
+	})
+
+	It("Seeds persistence data Before Reset", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
 	It("Verifies Cluster Reset", func() {
 		testcase.TestClusterReset(cluster)
 	})
@@ -52,6 +66,14 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady())
 	})
 
+	It("Validates data persistence After Reset", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
+	It("Reports cluster state changes After Reset", func() {
+		testcase.TestClusterStateDiff(snapshot)
+	})
+
 	It("Verifies Ingress After Reset", func() {
 		testcase.TestIngress(true, true)
 	})
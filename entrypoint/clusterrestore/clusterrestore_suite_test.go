@@ -34,6 +34,13 @@ func TestMain(m *testing.M) {
 	flag.Var(&flags.Channel, "channel", "channel to use on install")
 	flag.StringVar(&flags.S3Flags.Bucket, "s3Bucket", "distrosqa", "s3 bucket to store snapshots")
 	flag.StringVar(&flags.S3Flags.Folder, "s3Folder", "snapshots", "s3 folder to store snapshots")
+	flag.StringVar(&flags.S3Flags.Endpoint, "s3Endpoint", "", "S3-compatible endpoint URL, e.g. http://minio:9000")
+	flag.StringVar(&flags.S3Flags.Region, "s3Region", "", "s3 region, defaults to the cluster region")
+	flag.StringVar(&flags.S3Flags.AccessKey, "s3AccessKey", "", "s3 access key, defaults to the aws access key")
+	flag.StringVar(&flags.S3Flags.SecretKey, "s3SecretKey", "", "s3 secret key, defaults to the aws secret key")
+	flag.BoolVar(&flags.S3Flags.PathStyle, "s3PathStyle", false, "use path-style bucket access")
+	flag.BoolVar(&flags.S3Flags.SkipTLSVerify, "s3SkipTLSVerify", false, "skip TLS verification of the s3 endpoint")
+	flag.StringVar(&flags.S3Flags.EndpointCA, "s3EndpointCA", "", "path to the CA PEM file of the s3 endpoint")
 	flag.Parse()
 
 	cfg, err = config.AddEnv()
@@ -60,6 +67,22 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	if flags.S3Flags.Endpoint != "" {
+		awsClient, err = awsClient.UseS3Endpoint(&aws.S3Config{
+			Endpoint:      flags.S3Flags.Endpoint,
+			Region:        flags.S3Flags.Region,
+			AccessKey:     flags.S3Flags.AccessKey,
+			SecretKey:     flags.S3Flags.SecretKey,
+			PathStyle:     flags.S3Flags.PathStyle,
+			SkipTLSVerify: flags.S3Flags.SkipTLSVerify,
+			CACertFile:    flags.S3Flags.EndpointCA,
+		})
+		if err != nil {
+			shared.LogLevel("error", "error configuring s3 endpoint: %s", err)
+			os.Exit(1)
+		}
+	}
+
 	os.Exit(m.Run())
 }
 
@@ -4,12 +4,18 @@ import (
 	"fmt"
 
 	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
 	"github.com/rancher/distros-test-framework/pkg/testcase"
 
 	. "github.com/onsi/ginkgo/v2"
 )
 
 var _ = Describe("Test:", func() {
+	var (
+		persistence *testcase.PersistenceData
+		snapshot    *k8s.ClusterSnapshot
+	)
+
 	It("Start Up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -37,6 +43,14 @@ var _ = Describe("Test:", func() {
 		testcase.TestServiceNodePort(true, false)
 	})
 
+	It("Takes a cluster state snapshot", func() {
+		snapshot = testcase.SnapshotClusterState()
+	})
+
+	It("Seeds persistence data Before Restore", func() {
+		persistence = testcase.SeedPersistenceData(false)
+	})
+
 	It("Verifies Cluster Reset Restore", func() {
 		testcase.TestClusterRestore(cluster, awsClient, cfg, flags)
 	})
@@ -48,6 +62,14 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady())
 	})
 
+	It("Validates data persistence after Restore", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
+	It("Reports cluster state changes after Restore", func() {
+		testcase.TestClusterStateDiff(snapshot)
+	})
+
 	It("Verifies ClusterIP Service after Restore with new deployment", func() {
 		testcase.TestServiceClusterIP(true, true)
 	})
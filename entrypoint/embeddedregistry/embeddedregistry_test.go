@@ -0,0 +1,60 @@
+package embeddedregistry
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/testcase"
+)
+
+var _ = Describe("Test:", func() {
+	It("Start Up with no issues", func() {
+		testcase.TestBuildCluster(cluster)
+	})
+
+	It("Validate Nodes", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pods", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady(),
+		)
+	})
+
+	It("Validate Embedded Registry", func() {
+		testcase.TestEmbeddedRegistry(cluster)
+	})
+
+	It("Validate Nodes", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pods", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady(),
+		)
+	})
+})
+
+var _ = AfterEach(func() {
+	if CurrentSpecReport().Failed() {
+		fmt.Printf("\nFAILED! %s\n\n", CurrentSpecReport().FullText())
+	} else {
+		fmt.Printf("\nPASSED! %s\n\n", CurrentSpecReport().FullText())
+	}
+})
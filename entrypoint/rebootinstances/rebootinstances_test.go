@@ -10,6 +10,8 @@ import (
 )
 
 var _ = Describe("Test:", func() {
+	var persistence *testcase.PersistenceData
+
 	It("Start Up with no issues on rebootinstances test", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -45,6 +47,10 @@ var _ = Describe("Test:", func() {
 		testcase.TestDaemonset(true, true)
 	})
 
+	It("Seeds persistence data", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
 	It("Reboot server and agent nodes", func() {
 		testcase.TestRebootInstances(cluster)
 	})
@@ -57,6 +63,10 @@ var _ = Describe("Test:", func() {
 		)
 	})
 
+	It("Validates data persistence after reboot", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
 	It("Verifies node CPU usage does not exceed 80% after reboot", func() {
 		testcase.TestNodeCPUThreshold(80, false, true)
 	})
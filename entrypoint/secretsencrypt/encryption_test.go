@@ -10,6 +10,8 @@ import (
 )
 
 var _ = Describe("Test:", func() {
+	var persistence *testcase.PersistenceData
+
 	It("Start Up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -29,6 +31,10 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady())
 	})
 
+	It("Seeds persistence data", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
 	It("Validate Secrets Encryption", func() {
 		testcase.TestSecretsEncryption(cluster, flags)
 	})
@@ -47,6 +53,10 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertRestart(),
 			assert.PodAssertReady())
 	})
+
+	It("Validates data persistence after secrets encryption", func() {
+		testcase.TestDataPersistence(persistence)
+	})
 })
 
 var _ = AfterEach(func() {
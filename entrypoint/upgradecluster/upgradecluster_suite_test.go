@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -13,6 +14,8 @@ import (
 	"github.com/rancher/distros-test-framework/pkg/customflag"
 	"github.com/rancher/distros-test-framework/pkg/k8s"
 	"github.com/rancher/distros-test-framework/pkg/qase"
+	"github.com/rancher/distros-test-framework/pkg/suc"
+	"github.com/rancher/distros-test-framework/pkg/testcase"
 	"github.com/rancher/distros-test-framework/shared"
 )
 
@@ -22,6 +25,9 @@ var (
 	flags         *customflag.FlagConfig
 	cluster       *shared.Cluster
 	k8sClient     *k8s.Client
+	upgradePath   []string
+	probe         *testcase.AvailabilityProbe
+	validations   []string
 	cfg           *config.Env
 	reportSummary string
 	reportErr     error
@@ -34,6 +40,26 @@ func TestMain(m *testing.M) {
 	flag.Var(&flags.Channel, "channel", "channel to use on upgrade")
 	flag.Var(&flags.Destroy, "destroy", "Destroy cluster after test")
 	flag.Var(&flags.SUCUpgradeVersion, "sucUpgradeVersion", "Version for upgrading using SUC")
+	flags.SUCControllerVersion.Version = suc.DefaultVersion
+	flag.Var(&flags.SUCControllerVersion, "sucControllerVersion", "Vendored system-upgrade-controller version to deploy")
+	flag.IntVar(&flags.SUCPlan.ServerConcurrency, "sucServerConcurrency", 0, "Nodes upgraded at once by the SUC server plans")
+	flag.IntVar(&flags.SUCPlan.AgentConcurrency, "sucAgentConcurrency", 0, "Nodes upgraded at once by the SUC agent plan")
+	flag.StringVar(&flags.SUCPlan.Cordon, "sucCordon", "", "Cordon nodes before the SUC upgrade: true or false")
+	flag.StringVar(&flags.SUCPlan.Drain, "sucDrain", "", "Drain nodes before the SUC upgrade: true or false")
+	flag.StringVar(&flags.SUCPlan.DrainTimeout, "sucDrainTimeout", "", "Timeout of the SUC drain, e.g. 300s")
+	flag.DurationVar(&flags.SUCPlan.Timeout, "sucUpgradeTimeout", 30*time.Minute,
+		"Time to wait for the SUC plans to upgrade every node")
+	flag.BoolVar(&flags.AvailabilityProbe.Enabled, "availabilityProbe", false,
+		"Probe workload availability during the upgrade")
+	flag.Float64Var(&flags.AvailabilityProbe.MinSuccessRate, "probeMinSuccessRate", 99,
+		"Minimum http and dns success rate in percent during the upgrade")
+	flag.DurationVar(&flags.AvailabilityProbe.MaxOutage, "probeMaxOutage", 10*time.Second,
+		"Longest allowed outage during the upgrade")
+	flag.Var(&flags.UpgradePath, "upgradePath", "Comma separated versions, channels or minor ranges to upgrade through")
+	flag.StringVar(&flags.UpgradePath.Method, "upgradeMethod", "manual", "Upgrade method for every hop: manual or suc")
+	flag.StringVar(&flags.UpgradePath.Validations, "hopValidations", "",
+		"Comma separated validations after every hop: nodes,pods,version,data")
+	flag.BoolVar(&flags.NodeArgs, "nodeArgs", false, "Validate node args consistency after the manual upgrade")
 	flag.Parse()
 
 	cfg, err = config.AddEnv()
@@ -57,6 +83,20 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	if len(flags.UpgradePath.Hops) > 0 {
+		upgradePath, err = testcase.ResolveUpgradePath(cluster.Config.Product, flags.UpgradePath.Hops)
+		if err != nil {
+			shared.LogLevel("error", "error resolving upgrade path: %w\n", err)
+			os.Exit(1)
+		}
+
+		validations, err = testcase.ParseUpgradeValidations(flags.UpgradePath.Validations)
+		if err != nil {
+			shared.LogLevel("error", "error parsing hop validations: %w\n", err)
+			os.Exit(1)
+		}
+	}
+
 	os.Exit(m.Run())
 }
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/rancher/distros-test-framework/pkg/assert"
 	"github.com/rancher/distros-test-framework/pkg/customflag"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
 	"github.com/rancher/distros-test-framework/pkg/testcase"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -14,6 +15,11 @@ import (
 )
 
 var _ = Describe("Test:", func() {
+	var (
+		persistence *testcase.PersistenceData
+		snapshot    *k8s.ClusterSnapshot
+	)
+
 	It("Start Up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -71,11 +77,31 @@ var _ = Describe("Test:", func() {
 		})
 	}
 
+	It("Takes a cluster state snapshot", func() {
+		snapshot = testcase.SnapshotClusterState()
+	})
+
+	It("Seeds persistence data pre-upgrade", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
+	if flags.AvailabilityProbe.Enabled {
+		It("Starts availability probe", func() {
+			probe = testcase.StartAvailabilityProbe()
+		})
+	}
+
 	It("Upgrade Manual", func() {
 		upgradeErr := testcase.TestUpgradeClusterManual(cluster, k8sClient, flags.InstallMode.String())
 		Expect(upgradeErr).To(BeNil(), "Error upgrading cluster: %v", upgradeErr)
 	})
 
+	if flags.AvailabilityProbe.Enabled {
+		It("Validates workload availability during upgrade", func() {
+			testcase.TestUpgradeAvailability(probe, flags)
+		})
+	}
+
 	It("Checks Node Status after upgrade and validate version", func() {
 		testcase.TestNodeStatus(
 			cluster,
@@ -91,6 +117,20 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady())
 	})
 
+	It("Validates data persistence after upgrade", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
+	It("Reports cluster state changes after upgrade", func() {
+		testcase.TestClusterStateDiff(snapshot)
+	})
+
+	if flags.NodeArgs {
+		It("Validates node args consistency after upgrade", func() {
+			testcase.TestNodeArgsConsistency(cluster)
+		})
+	}
+
 	It("Validate Metrics Server after upgrade", func() {
 		testcase.TestNodeMetricsServer(true, true)
 	})
@@ -45,10 +45,22 @@ var _ = Describe("Upgrade Node Replacement Test:", Ordered, func() {
 		testcase.TestIngress(true, false)
 	})
 
+	if flags.AvailabilityProbe.Enabled {
+		It("Starts availability probe", func() {
+			probe = testcase.StartAvailabilityProbe()
+		})
+	}
+
 	It("Upgrade by Node replacement", func() {
 		testcase.TestUpgradeReplaceNode(cluster, flags)
 	})
 
+	if flags.AvailabilityProbe.Enabled {
+		It("Validates workload availability during upgrade", func() {
+			testcase.TestUpgradeAvailability(probe, flags)
+		})
+	}
+
 	It("Checks Node Status after upgrade and validate version", func() {
 		testcase.TestNodeStatus(
 			cluster,
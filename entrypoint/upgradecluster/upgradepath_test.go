@@ -0,0 +1,62 @@
+//go:build upgradepath
+
+package upgradecluster
+
+import (
+	"fmt"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/testcase"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade Path Tests:", Ordered, func() {
+	It("Start Up with no issues", func() {
+		testcase.TestBuildCluster(cluster)
+	})
+
+	It("Validate Node", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pod", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady())
+	})
+
+	It("Verifies ClusterIP Service pre-upgrade", func() {
+		testcase.TestServiceClusterIP(true, false)
+	})
+
+	It("Seeds data to validate persistence across hops", func() {
+		Expect(upgradePath).NotTo(BeEmpty(), "upgrade path should be provided with -upgradePath")
+		testcase.TestUpgradePathSeedData()
+	})
+
+	for i, version := range upgradePath {
+		It(fmt.Sprintf("Upgrade hop %d/%d via %s to %s", i+1, len(upgradePath), flags.UpgradePath.Method, version),
+			func() {
+				testcase.TestUpgradePathHop(cluster, k8sClient, i+1, version, flags.UpgradePath.Method, validations)
+			})
+	}
+
+	It("Verifies ClusterIP Service after upgrade path", func() {
+		testcase.TestServiceClusterIP(false, true)
+	})
+})
+
+var _ = AfterEach(func() {
+	if CurrentSpecReport().Failed() {
+		fmt.Printf("\nFAILED! %s\n\n", CurrentSpecReport().FullText())
+	} else {
+		fmt.Printf("\nPASSED! %s\n\n", CurrentSpecReport().FullText())
+	}
+})
@@ -0,0 +1,76 @@
+//go:build upgraderollback
+
+package upgradecluster
+
+import (
+	"fmt"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/testcase"
+
+	. "github.com/onsi/ginkgo/v2"
+)
+
+var _ = Describe("Upgrade Rollback Tests:", func() {
+	It("Start Up with no issues", func() {
+		testcase.TestBuildCluster(cluster)
+	})
+
+	It("Validate Node", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			nil,
+		)
+	})
+
+	It("Validate Pod", func() {
+		testcase.TestPodStatus(
+			cluster,
+			assert.PodAssertRestart(),
+			assert.PodAssertReady())
+	})
+
+	It("Verifies ClusterIP Service pre-upgrade", func() {
+		testcase.TestServiceClusterIP(true, false)
+	})
+
+	It("Verifies Daemonset pre-upgrade", func() {
+		testcase.TestDaemonset(true, false)
+	})
+
+	It("Upgrades and rolls back via etcd snapshot restore", func() {
+		testcase.TestUpgradeRollback(cluster, k8sClient, flags)
+	})
+
+	It("Checks Node Status after rollback and validate version", func() {
+		testcase.TestNodeStatus(
+			cluster,
+			assert.NodeAssertReadyStatus(),
+			assert.NodeAssertNot(assert.NodeAssertVersionTypeUpgrade(flags)),
+		)
+	})
+
+	It("Checks Pod Status after rollback", func() {
+		testcase.TestPodStatus(
+			cluster,
+			nil,
+			assert.PodAssertReady())
+	})
+
+	It("Verifies ClusterIP Service after rollback", func() {
+		testcase.TestServiceClusterIP(false, true)
+	})
+
+	It("Verifies Daemonset after rollback", func() {
+		testcase.TestDaemonset(false, true)
+	})
+})
+
+var _ = AfterEach(func() {
+	if CurrentSpecReport().Failed() {
+		fmt.Printf("\nFAILED! %s\n\n", CurrentSpecReport().FullText())
+	} else {
+		fmt.Printf("\nPASSED! %s\n\n", CurrentSpecReport().FullText())
+	}
+})
@@ -6,12 +6,19 @@ import (
 	"fmt"
 
 	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
 	"github.com/rancher/distros-test-framework/pkg/testcase"
 
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("SUC Upgrade Tests:", func() {
+	var (
+		persistence *testcase.PersistenceData
+		snapshot    *k8s.ClusterSnapshot
+	)
+
 	It("Starts up with no issues", func() {
 		testcase.TestBuildCluster(cluster)
 	})
@@ -73,10 +80,31 @@ var _ = Describe("SUC Upgrade Tests:", func() {
 		})
 	}
 
+	It("Takes a cluster state snapshot", func() {
+		snapshot = testcase.SnapshotClusterState()
+	})
+
+	It("Seeds persistence data pre-upgrade", func() {
+		persistence = testcase.SeedPersistenceData(true)
+	})
+
+	if flags.AvailabilityProbe.Enabled {
+		It("Starts availability probe", func() {
+			probe = testcase.StartAvailabilityProbe()
+		})
+	}
+
 	It("\nUpgrade via SUC", func() {
-		_ = testcase.TestUpgradeClusterSUC(cluster, k8sClient, flags.SUCUpgradeVersion.String())
+		upgradeErr := testcase.TestUpgradeClusterSUC(cluster, k8sClient, flags.SUCUpgradeVersion.String())
+		Expect(upgradeErr).NotTo(HaveOccurred(), "Error upgrading cluster: %v", upgradeErr)
 	})
 
+	if flags.AvailabilityProbe.Enabled {
+		It("Validates workload availability during upgrade", func() {
+			testcase.TestUpgradeAvailability(probe, flags)
+		})
+	}
+
 	It("Checks Node status post-upgrade", func() {
 		testcase.TestNodeStatus(
 			cluster,
@@ -93,6 +121,14 @@ var _ = Describe("SUC Upgrade Tests:", func() {
 			assert.PodAssertReady())
 	})
 
+	It("Validates data persistence post-upgrade", func() {
+		testcase.TestDataPersistence(persistence)
+	})
+
+	It("Reports cluster state changes post-upgrade", func() {
+		testcase.TestClusterStateDiff(snapshot)
+	})
+
 	It("Validate Metrics Server post-upgrade", func() {
 		testcase.TestNodeMetricsServer(true, false)
 	})
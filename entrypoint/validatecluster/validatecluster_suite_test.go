@@ -32,6 +32,7 @@ func TestMain(m *testing.M) {
 	flag.Var(&flags.Destroy, "destroy", "Destroy cluster after test")
 	flag.Var(&flags.SelinuxTest, "selinux", "Run selinux test")
 	flag.Var(&flags.KillAllUninstallTest, "killalluninstall", "Run killall-uninstall test")
+	flag.BoolVar(&flags.NodeArgs, "nodeArgs", false, "Run node args consistency test")
 	flag.Parse()
 
 	cfg, err = config.AddEnv()
@@ -30,6 +30,18 @@ var _ = Describe("Test:", func() {
 			assert.PodAssertReady())
 	})
 
+	if cluster.Config.SplitRoles.Enabled {
+		It("Validate Node Roles", func() {
+			testcase.TestNodeRoles(cluster)
+		})
+	}
+
+	if flags.NodeArgs {
+		It("Validate Node Args", func() {
+			testcase.TestNodeArgsConsistency(cluster)
+		})
+	}
+
 	It("Verifies node CPU usage does not exceed 80% before reboot", func() {
 		testcase.TestNodeCPUThreshold(80, true, false)
 	})
@@ -61,6 +61,24 @@ func NodeAssertVersionUpgraded() NodeAssertFunc {
 	}
 }
 
+// NodeAssertVersion custom assertion func that asserts that node version matches the given version.
+func NodeAssertVersion(version string) NodeAssertFunc {
+	return func(g Gomega, node shared.Node) {
+		g.Expect(node.Version).Should(ContainSubstring(strings.Split(version, "-")[0]),
+			"Nodes should all be upgraded to the specified version", node.Name)
+	}
+}
+
+// NodeAssertNot custom assertion func that asserts that the given node assertion does not match,
+// e.g. NodeAssertNot(NodeAssertVersionTypeUpgrade(c)) after rolling back an upgrade.
+func NodeAssertNot(nodeAssert NodeAssertFunc) NodeAssertFunc {
+	return func(g Gomega, node shared.Node) {
+		matched := true
+		nodeAssert(NewGomega(func(_ string, _ ...int) { matched = false }), node)
+		g.Expect(matched).To(BeFalse(), "Node should not match the assertion", node.Name)
+	}
+}
+
 // NodeAssertReadyStatus custom assertion func that asserts that the node is in Ready state.
 func NodeAssertReadyStatus() NodeAssertFunc {
 	return func(g Gomega, node shared.Node) {
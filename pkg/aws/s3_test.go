@@ -0,0 +1,190 @@
+package aws
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+// minioClient returns a client using the MinIO endpoint from S3_TEST_ENDPOINT, skipping the test when it is not set.
+// S3_TEST_ACCESS_KEY and S3_TEST_SECRET_KEY default to the credentials of the MinIO setup in docs/testing.md.
+func minioClient(t *testing.T) *Client {
+	t.Helper()
+
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set, e.g. http://localhost:9000 for a local MinIO")
+	}
+
+	accessKey, secretKey := os.Getenv("S3_TEST_ACCESS_KEY"), os.Getenv("S3_TEST_SECRET_KEY")
+	if accessKey == "" {
+		accessKey = "minio"
+	}
+	if secretKey == "" {
+		secretKey = "minio123"
+	}
+
+	base, err := AddClient(&shared.Cluster{Aws: shared.AwsConfig{Region: "us-east-1"}})
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+
+	c, err := base.UseS3Endpoint(&S3Config{
+		Endpoint:  endpoint,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("cannot use endpoint %s: %v", endpoint, err)
+	}
+	if c.s3 == base.s3 {
+		t.Fatal("UseS3Endpoint changed the S3 client of the original client")
+	}
+
+	return c
+}
+
+// testBucket creates a bucket for the test and removes it with its objects when the test ends.
+func testBucket(t *testing.T, c *Client) string {
+	t.Helper()
+
+	bucket := fmt.Sprintf("distros-test-%d", time.Now().UnixNano())
+	if _, err := c.s3.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatalf("cannot create bucket %s: %v", bucket, err)
+	}
+
+	t.Cleanup(func() {
+		objects, err := c.GetObjects(bucket)
+		if err != nil {
+			t.Logf("cannot list bucket %s for cleanup: %v", bucket, err)
+		}
+		for _, obj := range objects {
+			_, _ = c.s3.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: obj.Key})
+		}
+		if _, err := c.s3.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			t.Logf("cannot delete bucket %s: %v", bucket, err)
+		}
+	})
+
+	return bucket
+}
+
+func TestS3EndpointObjects(t *testing.T) {
+	c := minioClient(t)
+	bucket := testBucket(t, c)
+
+	for _, key := range []string{"snapshots/etcd-snapshot-1", "snapshots/etcd-snapshot-2", "etcd-snapshot-3"} {
+		if _, err := c.s3.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("snapshot"),
+		}); err != nil {
+			t.Fatalf("cannot put %s: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		folder     string
+		objectName string
+		want       []string
+	}{
+		{
+			name:       "object in folder",
+			folder:     "snapshots",
+			objectName: "etcd-snapshot-1",
+			want:       []string{"etcd-snapshot-3", "snapshots/etcd-snapshot-2"},
+		},
+		{
+			name:       "object without folder",
+			objectName: "etcd-snapshot-3",
+			want:       []string{"snapshots/etcd-snapshot-2"},
+		},
+		{
+			name:       "missing object",
+			folder:     "snapshots",
+			objectName: "etcd-snapshot-1",
+			want:       []string{"snapshots/etcd-snapshot-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.DeleteS3Object(bucket, tt.folder, tt.objectName); err != nil {
+				t.Fatalf("cannot delete %s/%s: %v", tt.folder, tt.objectName, err)
+			}
+
+			objects, err := c.GetObjects(bucket)
+			if err != nil {
+				t.Fatalf("cannot list bucket %s: %v", bucket, err)
+			}
+
+			var keys []string
+			for _, obj := range objects {
+				keys = append(keys, aws.StringValue(obj.Key))
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, tt.want) {
+				t.Fatalf("expected objects %v, got %v", tt.want, keys)
+			}
+		})
+	}
+}
+
+func TestUseS3EndpointCA(t *testing.T) {
+	caFile := t.TempDir() + "/ca.pem"
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("cannot write CA file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *S3Config
+		wantErr string
+	}{
+		{name: "plain endpoint", cfg: &S3Config{Endpoint: "http://localhost:9000", PathStyle: true}},
+		{name: "skip tls verify", cfg: &S3Config{Endpoint: "https://localhost:9000", SkipTLSVerify: true}},
+		{
+			name:    "missing CA file",
+			cfg:     &S3Config{Endpoint: "https://localhost:9000", CACertFile: caFile + ".missing"},
+			wantErr: "error reading S3 endpoint CA",
+		},
+		{
+			name:    "CA file without certificates",
+			cfg:     &S3Config{Endpoint: "https://localhost:9000", CACertFile: caFile},
+			wantErr: "no certificates found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := Client{infra: &shared.Cluster{Aws: shared.AwsConfig{Region: "us-east-1"}}}
+
+			c, err := base.UseS3Endpoint(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.s3 == nil || base.s3 != nil {
+				t.Fatal("expected only the returned client to use the endpoint")
+			}
+			if got := aws.StringValue(c.s3.Client.Config.Endpoint); got != tt.cfg.Endpoint {
+				t.Fatalf("expected endpoint %s, got %s", tt.cfg.Endpoint, got)
+			}
+		})
+	}
+}
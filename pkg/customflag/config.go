@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -19,6 +20,10 @@ type FlagConfig struct {
 	TestTemplateConfig   templateConfigFlag
 	Destroy              destroyFlag
 	SUCUpgradeVersion    sucUpgradeVersionFlag
+	SUCPlan              sucPlanFlag
+	SUCControllerVersion sucControllerVersionFlag
+	UpgradePath          upgradePathFlag
+	AvailabilityProbe    availabilityProbeFlag
 	Channel              channelFlag
 	External             externalFlag
 	CertManager          certManagerFlag
@@ -29,6 +34,8 @@ type FlagConfig struct {
 	KillAllUninstallTest killalluninstallTestFlag
 	SecretsEncrypt       secretsEncryptFlag
 	Nvidia               nvidiaFlag
+	CertificateReport    bool
+	NodeArgs             bool
 }
 
 type nvidiaFlag struct {
@@ -162,6 +169,66 @@ func (t *sucUpgradeVersionFlag) Set(value string) error {
 	return nil
 }
 
+type sucControllerVersionFlag struct {
+	Version string
+}
+
+func (s *sucControllerVersionFlag) String() string {
+	return s.Version
+}
+
+func (s *sucControllerVersionFlag) Set(value string) error {
+	if !strings.HasPrefix(value, "v") {
+		return fmt.Errorf("invalid suc controller version format: %s", value)
+	}
+
+	s.Version = value
+
+	return nil
+}
+
+// sucPlanFlag holds the overrides applied to the SUC plan templates, empty values keep the template settings.
+type sucPlanFlag struct {
+	ServerConcurrency int
+	AgentConcurrency  int
+	Cordon            string
+	Drain             string
+	DrainTimeout      string
+	Timeout           time.Duration
+}
+
+// upgradePathFlag holds the ordered hops of a multi-hop upgrade.
+// A hop is a version, a channel like v1.31 or stable resolved to its latest release,
+// or a minor range like v1.30..v1.32 expanded to one channel per minor.
+type upgradePathFlag struct {
+	Hops        []string
+	Method      string
+	Validations string
+}
+
+func (u *upgradePathFlag) String() string {
+	return strings.Join(u.Hops, ",")
+}
+
+func (u *upgradePathFlag) Set(value string) error {
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			return fmt.Errorf("invalid upgrade path: %s", value)
+		}
+		u.Hops = append(u.Hops, hop)
+	}
+
+	return nil
+}
+
+// availabilityProbeFlag holds the thresholds the workload availability during an upgrade is validated against.
+type availabilityProbeFlag struct {
+	Enabled        bool
+	MinSuccessRate float64
+	MaxOutage      time.Duration
+}
+
 type destroyFlag bool
 
 func (d *destroyFlag) String() string {
@@ -203,9 +270,18 @@ type certManagerFlag struct {
 	Version string
 }
 
+// s3ConfigFlag holds the etcd snapshot S3 settings.
+// Endpoint and the options after it are only needed for S3-compatible stores like MinIO.
 type s3ConfigFlag struct {
-	Bucket string
-	Folder string
+	Bucket        string
+	Folder        string
+	Endpoint      string
+	Region        string
+	AccessKey     string
+	SecretKey     string
+	PathStyle     bool
+	SkipTLSVerify bool
+	EndpointCA    string
 }
 
 type selinuxTestFlag bool
@@ -368,6 +368,7 @@ func ValidateTemplateTcs() {
 		"TestSecretsEncryption":            {},
 		"TestRestartService":               {},
 		"TestClusterReset":                 {},
+		"TestEtcdSnapshot":                 {},
 	}
 
 	tcs := os.Getenv("TEST_CASE")
@@ -0,0 +1,263 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	planLabel     = "upgrade.cattle.io/plan"
+	planNodeLabel = "upgrade.cattle.io/node"
+	jobLogLines   = int64(50)
+)
+
+var planGVR = schema.GroupVersionResource{Group: "upgrade.cattle.io", Version: "v1", Resource: "plans"}
+
+// PlanStatus is the progress of a system-upgrade-controller plan.
+type PlanStatus struct {
+	Name          string
+	LatestVersion string
+	LatestHash    string
+	Applying      []string
+}
+
+// ErrUpgradeJobFailed is returned when a system-upgrade-controller job fails on a node.
+var ErrUpgradeJobFailed = errors.New("upgrade job failed")
+
+// WaitForPlansComplete watches the system-upgrade-controller plans in the namespace until every node
+// reports the version and no plan is applying, or the timeout expires.
+//
+// it fails fast when an upgrade job fails, returning the node and the logs of the job.
+//
+// API errors are expected while the servers restart and are retried until the timeout.
+func (k *Client) WaitForPlansComplete(namespace, version string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	var watcher watch.Interface
+	defer func() {
+		if watcher != nil {
+			watcher.Stop()
+		}
+	}()
+
+	seen := make(map[string]PlanStatus)
+	for {
+		done, progressErr := k.checkPlansProgress(ctx, namespace, version)
+		if errors.Is(progressErr, ErrUpgradeJobFailed) {
+			return progressErr
+		}
+		if progressErr != nil && ctx.Err() == nil {
+			shared.LogLevel("warn", "Checking upgrade progress: %v", progressErr)
+		}
+		if done {
+			return nil
+		}
+
+		// the API server closes watches periodically and while it restarts, the ticker keeps polling meanwhile.
+		var events <-chan watch.Event
+		if watcher == nil {
+			watcher, _ = k.DynamicClient.Resource(planGVR).Namespace(namespace).Watch(ctx, meta.ListOptions{})
+		}
+		if watcher != nil {
+			events = watcher.ResultChan()
+		}
+
+		select {
+		case <-ctx.Done():
+			return k.planTimeoutError(namespace, version, timeout)
+		case event, ok := <-events:
+			if !ok {
+				watcher.Stop()
+				watcher = nil
+
+				continue
+			}
+
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+
+			status := planStatus(obj)
+			if prev, found := seen[status.Name]; !found || !slices.Equal(prev.Applying, status.Applying) ||
+				prev.LatestHash != status.LatestHash {
+				shared.LogLevel("info", "Plan %s: version %s, hash %s, applying %v",
+					status.Name, status.LatestVersion, status.LatestHash, status.Applying)
+			}
+			seen[status.Name] = status
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetPlanStatuses returns the status of the system-upgrade-controller plans in the namespace.
+func (k *Client) GetPlanStatuses(namespace string) ([]PlanStatus, error) {
+	list, err := k.DynamicClient.Resource(planGVR).Namespace(namespace).List(context.Background(), meta.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	statuses := make([]PlanStatus, 0, len(list.Items))
+	for i := range list.Items {
+		statuses = append(statuses, planStatus(&list.Items[i]))
+	}
+
+	return statuses, nil
+}
+
+// checkPlansProgress returns true when every node runs the version and no plan is applying.
+func (k *Client) checkPlansProgress(ctx context.Context, namespace, version string) (bool, error) {
+	if failedErr := k.failedUpgradeJob(ctx, namespace); failedErr != nil {
+		return false, failedErr
+	}
+
+	statuses, err := k.GetPlanStatuses(namespace)
+	if err != nil {
+		return false, err
+	}
+	if len(statuses) == 0 {
+		return false, fmt.Errorf("no plans found in namespace %s", namespace)
+	}
+
+	pending, err := k.nodesNotAtVersion(ctx, version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, status := range statuses {
+		if len(status.Applying) > 0 {
+			return false, nil
+		}
+	}
+
+	return len(pending) == 0, nil
+}
+
+// failedUpgradeJob returns an error with the node and job logs of the first failed upgrade job.
+func (k *Client) failedUpgradeJob(ctx context.Context, namespace string) error {
+	jobs, err := k.Clientset.BatchV1().Jobs(namespace).List(ctx, meta.ListOptions{LabelSelector: planLabel})
+	if err != nil {
+		return fmt.Errorf("failed to list upgrade jobs: %w", err)
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if !jobFailed(job) {
+			continue
+		}
+
+		node := job.Labels[planNodeLabel]
+		shared.LogLevel("error", "Upgrade job %s for plan %s failed on node %s", job.Name, job.Labels[planLabel], node)
+
+		return fmt.Errorf("%w: job %s on node %s:\n%s", ErrUpgradeJobFailed, job.Name, node, k.jobLogs(ctx, job))
+	}
+
+	return nil
+}
+
+// nodesNotAtVersion returns the nodes whose kubelet does not report the version yet.
+func (k *Client) nodesNotAtVersion(ctx context.Context, version string) ([]string, error) {
+	nodes, err := k.Clientset.CoreV1().Nodes().List(ctx, meta.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	expected := strings.Split(version, "-")[0]
+
+	var pending []string
+	for i := range nodes.Items {
+		if !strings.Contains(nodes.Items[i].Status.NodeInfo.KubeletVersion, expected) {
+			pending = append(pending, nodes.Items[i].Name)
+		}
+	}
+
+	return pending, nil
+}
+
+// jobLogs returns the last lines of every container of the job pods.
+func (k *Client) jobLogs(ctx context.Context, job *batch.Job) string {
+	pods, err := k.Clientset.CoreV1().Pods(job.Namespace).List(ctx, meta.ListOptions{
+		LabelSelector: "job-name=" + job.Name,
+	})
+	if err != nil {
+		return fmt.Sprintf("failed to list pods of job %s: %v", job.Name, err)
+	}
+
+	var sb strings.Builder
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		containers := append(slices.Clone(pod.Spec.InitContainers), pod.Spec.Containers...)
+		for j := range containers {
+			logs, logErr := k.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container: containers[j].Name,
+				TailLines: ptr(jobLogLines),
+			}).DoRaw(ctx)
+			if logErr != nil {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("--- %s/%s ---\n%s\n", pod.Name, containers[j].Name, logs))
+		}
+	}
+
+	return sb.String()
+}
+
+func (k *Client) planTimeoutError(namespace, version string, timeout time.Duration) error {
+	msg := fmt.Sprintf("timed out after %s waiting for plans in %s to upgrade nodes to %s", timeout, namespace, version)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if pending, err := k.nodesNotAtVersion(ctx, version); err == nil {
+		msg += fmt.Sprintf(", nodes not upgraded: %v", pending)
+	}
+	if statuses, err := k.GetPlanStatuses(namespace); err == nil {
+		for _, status := range statuses {
+			if len(status.Applying) > 0 {
+				msg += fmt.Sprintf(", plan %s still applying %v", status.Name, status.Applying)
+			}
+		}
+	}
+
+	return errors.New(msg)
+}
+
+func planStatus(obj *unstructured.Unstructured) PlanStatus {
+	status := PlanStatus{Name: obj.GetName()}
+	status.LatestVersion, _, _ = unstructured.NestedString(obj.Object, "status", "latestVersion")
+	status.LatestHash, _, _ = unstructured.NestedString(obj.Object, "status", "latestHash")
+	status.Applying, _, _ = unstructured.NestedStringSlice(obj.Object, "status", "applying")
+
+	return status
+}
+
+func jobFailed(job *batch.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batch.JobFailed && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+// snapshotSkippedTypes are recreated or updated continuously and would only add noise to a diff.
+var snapshotSkippedTypes = []ResourceType{
+	ResourceTypePod,
+	ResourceTypeReplicaSet,
+	ResourceTypeEvent,
+	ResourceTypeLease,
+	ResourceTypeEndpoints,
+	ResourceTypeEndpointSlice,
+	ResourceTypeCustomResourceDefinition,
+}
+
+// volatileMetadata are the metadata fields set by the API server on every write.
+var volatileMetadata = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink",
+}
+
+// volatileAnnotations are the annotations updated by controllers without a change of the object.
+var volatileAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"control-plane.alpha.kubernetes.io/leader",
+	"node.alpha.kubernetes.io/ttl",
+	"volumes.kubernetes.io/controller-managed-attach-detach",
+}
+
+// ClusterSnapshot is a normalized dump of the cluster resources, keyed by "Kind namespace/name".
+//
+// status, volatile metadata and annotations are stripped and Secret values are replaced by their checksum.
+type ClusterSnapshot struct {
+	Taken   time.Time
+	Objects map[string]map[string]any
+}
+
+// ObjectChange is an object present in both snapshots with the fields that differ.
+type ObjectChange struct {
+	Key    string
+	Fields []string
+}
+
+// SnapshotDiff lists the objects that appeared, disappeared or changed between two snapshots.
+type SnapshotDiff struct {
+	Added   []string
+	Removed []string
+	Changed []ObjectChange
+}
+
+// Empty returns true when the snapshots have the same objects.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d SnapshotDiff) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed)))
+	for _, key := range d.Added {
+		sb.WriteString("+ " + key + "\n")
+	}
+	for _, key := range d.Removed {
+		sb.WriteString("- " + key + "\n")
+	}
+	for _, change := range d.Changed {
+		sb.WriteString("~ " + change.Key + ": " + strings.Join(change.Fields, ", ") + "\n")
+	}
+
+	return sb.String()
+}
+
+// TakeClusterSnapshot lists every resource of the ResourceType enum and of the CRDs installed in the cluster,
+// except the types in snapshotSkippedTypes, and normalizes them.
+//
+// the served types are discovered once per snapshot, types the server does not serve are skipped.
+func (k *Client) TakeClusterSnapshot() (*ClusterSnapshot, error) {
+	snapshot := &ClusterSnapshot{Taken: time.Now(), Objects: make(map[string]map[string]any)}
+
+	served, err := k.servedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for _, name := range ResourceTypeNames() {
+		resourceType := ResourceType(name)
+		if slices.Contains(snapshotSkippedTypes, resourceType) {
+			continue
+		}
+
+		gvr, ok := served[resourceType]
+		if !ok {
+			shared.LogLevel("debug", "Skipping %s in cluster snapshot: not served", resourceType)
+			continue
+		}
+
+		list, listErr := k.DynamicClient.Resource(gvr).List(ctx, meta.ListOptions{})
+		if listErr != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", resourceType, listErr)
+		}
+		snapshot.add(list)
+	}
+
+	crdResource, ok := served[ResourceTypeCustomResourceDefinition]
+	if !ok {
+		return nil, fmt.Errorf("%s not served", ResourceTypeCustomResourceDefinition)
+	}
+	crds, err := k.DynamicClient.Resource(crdResource).List(ctx, meta.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CRDs: %w", err)
+	}
+
+	for i := range crds.Items {
+		gvr, ok := crdGVR(&crds.Items[i])
+		if !ok {
+			continue
+		}
+
+		list, listErr := k.DynamicClient.Resource(gvr).List(ctx, meta.ListOptions{})
+		if listErr != nil {
+			shared.LogLevel("warn", "Skipping %s in cluster snapshot: %v", gvr.String(), listErr)
+			continue
+		}
+		snapshot.add(list)
+	}
+
+	shared.LogLevel("debug", "Cluster snapshot taken with %d objects", len(snapshot.Objects))
+
+	return snapshot, nil
+}
+
+// servedResources runs the API discovery once and returns the GVR of every listable kind the server serves.
+// Groups that fail discovery, e.g. an aggregated API that is down, are logged and left out of the result.
+func (k *Client) servedResources() (map[ResourceType]schema.GroupVersionResource, error) {
+	lists, err := k.Clientset.Discovery().ServerPreferredResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, fmt.Errorf("failed to discover served resources: %w", err)
+		}
+		shared.LogLevel("warn", "Partial API discovery for cluster snapshot: %v", err)
+	}
+
+	return listableKinds(lists), nil
+}
+
+// listableKinds maps the kinds of the discovered resources that support list to their GVR,
+// the first group serving a kind wins, like getGVR.
+func listableKinds(lists []*meta.APIResourceList) map[ResourceType]schema.GroupVersionResource {
+	kinds := make(map[ResourceType]schema.GroupVersionResource)
+
+	for _, list := range lists {
+		groupVersion, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for i := range list.APIResources {
+			resource := &list.APIResources[i]
+			if strings.Contains(resource.Name, "/") || !slices.Contains(resource.Verbs, "list") {
+				continue
+			}
+
+			if _, found := kinds[ResourceType(resource.Kind)]; !found {
+				kinds[ResourceType(resource.Kind)] = groupVersion.WithResource(resource.Name)
+			}
+		}
+	}
+
+	return kinds
+}
+
+// DiffClusterSnapshots returns the objects added, removed and changed from before to after.
+func DiffClusterSnapshots(before, after *ClusterSnapshot) SnapshotDiff {
+	var diff SnapshotDiff
+
+	for key, obj := range after.Objects {
+		prev, found := before.Objects[key]
+		if !found {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+
+		if fields := diffFields(flatten(prev), flatten(obj)); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ObjectChange{Key: key, Fields: fields})
+		}
+	}
+
+	for key := range before.Objects {
+		if _, found := after.Objects[key]; !found {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b ObjectChange) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return diff
+}
+
+func (s *ClusterSnapshot) add(list *unstructured.UnstructuredList) {
+	for i := range list.Items {
+		obj := &list.Items[i]
+
+		key := obj.GetKind() + " " + obj.GetName()
+		if obj.GetNamespace() != "" {
+			key = obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+		}
+		s.Objects[key] = normalize(obj)
+	}
+}
+
+// normalize returns the object without status, volatile metadata and annotations, and Secret values.
+func normalize(obj *unstructured.Unstructured) map[string]any {
+	normalized := obj.DeepCopy().Object
+	delete(normalized, "status")
+
+	for _, field := range volatileMetadata {
+		unstructured.RemoveNestedField(normalized, "metadata", field)
+	}
+	for _, annotation := range volatileAnnotations {
+		unstructured.RemoveNestedField(normalized, "metadata", "annotations", annotation)
+	}
+
+	if obj.GetKind() == string(ResourceTypeSecret) {
+		if data, ok := normalized["data"].(map[string]any); ok {
+			for name, value := range data {
+				sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+				data[name] = "sha256:" + hex.EncodeToString(sum[:])
+			}
+		}
+	}
+
+	return normalized
+}
+
+// crdGVR returns the GVR of the storage version of the CRD.
+func crdGVR(crd *unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	for _, v := range versions {
+		version, ok := v.(map[string]any)
+		if !ok || version["storage"] != true {
+			continue
+		}
+
+		name, _ := version["name"].(string)
+
+		return schema.GroupVersionResource{Group: group, Version: name, Resource: plural}, true
+	}
+
+	return schema.GroupVersionResource{}, false
+}
+
+// flatten returns the leaf values of the object keyed by their dotted path.
+func flatten(obj map[string]any) map[string]string {
+	fields := make(map[string]string)
+
+	var walk func(path string, value any)
+	walk = func(path string, value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				walk(path+"."+key, child)
+			}
+		case []any:
+			for i, child := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		default:
+			encoded, _ := json.Marshal(v)
+			fields[path] = string(encoded)
+		}
+	}
+
+	for key, value := range obj {
+		walk(key, value)
+	}
+
+	return fields
+}
+
+func diffFields(before, after map[string]string) []string {
+	var fields []string
+	for path, value := range after {
+		if prev, found := before[path]; !found || prev != value {
+			fields = append(fields, path)
+		}
+	}
+	for path := range before {
+		if _, found := after[path]; !found {
+			fields = append(fields, path)
+		}
+	}
+	slices.Sort(fields)
+
+	return fields
+}
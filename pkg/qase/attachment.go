@@ -0,0 +1,223 @@
+package qase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	"github.com/rancher/distros-test-framework/shared"
+)
+
+const (
+	// Qase limits: 32 Mb per file and 20 files per upload request.
+	maxAttachmentSize     = 32 * 1024 * 1024
+	maxAttachmentsPerCall = 20
+	maxLogExcerptSize     = 64 * 1024
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// uploadAttachments uploads the given files to Qase and returns the attachment hashes.
+// Files that are missing, empty or above the Qase size limit are skipped with a warning.
+func (c Client) uploadAttachments(ctx context.Context, paths []string) ([]string, error) {
+	var valid []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			shared.LogLevel("warn", "skipping attachment %s: %v", p, err)
+			continue
+		}
+		if info.Size() == 0 || info.Size() > maxAttachmentSize {
+			shared.LogLevel("warn", "skipping attachment %s: size %d bytes out of bounds", p, info.Size())
+			continue
+		}
+		valid = append(valid, p)
+	}
+
+	var hashes []string
+	for start := 0; start < len(valid); start += maxAttachmentsPerCall {
+		end := min(start+maxAttachmentsPerCall, len(valid))
+
+		files := make([]*os.File, 0, end-start)
+		for _, p := range valid[start:end] {
+			f, err := os.Open(p)
+			if err != nil {
+				shared.LogLevel("warn", "skipping attachment %s: %v", p, err)
+				continue
+			}
+			files = append(files, f)
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		res, httpRes, err := c.QaseAPI.AttachmentsAPI.UploadAttachment(ctx, projectID).File(files).Execute()
+		closeAttachmentFiles(files)
+		if err != nil {
+			return hashes, fmt.Errorf("failed to upload attachments: %w, response: %v", err, httpRes)
+		}
+
+		for _, a := range res.Result {
+			if h := a.GetHash(); h != "" {
+				hashes = append(hashes, h)
+			}
+		}
+	}
+
+	shared.LogLevel("debug", "uploaded %d attachments to Qase", len(hashes))
+
+	return hashes, nil
+}
+
+// closeAttachmentFiles closes the files of an upload, the ones already closed by the client are ignored.
+func closeAttachmentFiles(files []*os.File) {
+	for _, f := range files {
+		if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			shared.LogLevel("debug", "failed to close attachment %s: %v", f.Name(), err)
+		}
+	}
+}
+
+// newAttachmentsDir creates the temporary directory holding the attachment files of one report.
+// The returned cleanup removes it and is called once the attachments are uploaded.
+func newAttachmentsDir() (dir string, cleanup func()) {
+	dir, err := os.MkdirTemp("", "qase-attachments-")
+	if err != nil {
+		shared.LogLevel("warn", "failed to create attachments dir, skipping attachments: %v", err)
+		return "", func() {}
+	}
+
+	return dir, func() {
+		if removeErr := os.RemoveAll(dir); removeErr != nil {
+			shared.LogLevel("warn", "failed to remove attachments dir %s: %v", dir, removeErr)
+		}
+	}
+}
+
+// writeAttachmentFile writes content into a file of dir that can be uploaded as an attachment.
+// A name already used in dir gets a numeric suffix.
+func writeAttachmentFile(dir, name, content string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("no attachments dir for attachment %s", name)
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("no content to write for attachment %s", name)
+	}
+
+	if len(content) > maxLogExcerptSize {
+		content = content[len(content)-maxLogExcerptSize:]
+		content = "... (truncated)\n" + content
+	}
+
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	ext := filepath.Ext(name)
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, name)
+		if i > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create attachment %s: %w", path, err)
+		}
+
+		_, writeErr := f.WriteString(content)
+		if closeErr := f.Close(); writeErr == nil {
+			writeErr = closeErr
+		}
+		if writeErr != nil {
+			return "", fmt.Errorf("failed to write attachment %s: %w", path, writeErr)
+		}
+
+		return path, nil
+	}
+}
+
+// specReportAttachments writes into dir and collects the files to attach to a spec report result:
+// the log excerpt of every failed spec, every file registered under shared.ReportAttachmentEntry,
+// and the product journal error lines from each node when the suite failed.
+func specReportAttachments(dir string, cluster *shared.Cluster, report *Report) []string {
+	var paths []string
+	failed := false
+
+	for i := range report.SpecReports {
+		r := &report.SpecReports[i]
+
+		for _, entry := range r.ReportEntries {
+			if entry.Name == shared.ReportAttachmentEntry {
+				paths = append(paths, entry.Value.String())
+			}
+		}
+
+		if r.State.String() == passStatus || r.State.String() == skipStatus {
+			continue
+		}
+		failed = true
+
+		excerpt := fmt.Sprintf("Spec: %s\nState: %s\nMessage: %s\nLocation: %s\n\nStackTrace:\n%s\n\nOutput:\n%s",
+			r.FullText(), r.State.String(), r.Failure.Message, r.Failure.Location.String(),
+			r.Failure.Location.FullStackTrace, r.CapturedGinkgoWriterOutput+r.CapturedStdOutErr)
+
+		path, err := writeAttachmentFile(dir, r.LeafNodeText+".log", excerpt)
+		if err != nil {
+			shared.LogLevel("warn", "failed to write log excerpt for %s: %v", r.LeafNodeText, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	if failed && cluster != nil {
+		paths = append(paths, journalAttachments(dir, cluster)...)
+	}
+
+	return paths
+}
+
+// journalAttachments writes the product journal error lines of each cluster node into attachment files.
+func journalAttachments(dir string, cluster *shared.Cluster) []string {
+	var paths []string
+
+	nodeIPs := append(append([]string{}, cluster.ServerIPs...), cluster.AgentIPs...)
+	for _, ip := range nodeIPs {
+		logs := shared.GetJournalLogs("error", ip)
+		if logs == "" {
+			continue
+		}
+
+		path, err := writeAttachmentFile(dir, "journal-"+ip+".log", logs)
+		if err != nil {
+			shared.LogLevel("warn", "failed to write journal snippet for node %s: %v", ip, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// failedCaseAttachment writes the error log of a failed e2e test case into an attachment file.
+func failedCaseAttachment(dir string, tc *TestCase) string {
+	content := tc.StackTrace.Message
+	if tc.FailureDetails != nil && tc.FailureDetails.ErrorMessage != "" {
+		content = tc.FailureDetails.ErrorMessage
+	}
+
+	path, err := writeAttachmentFile(dir, tc.Name+".log", content)
+	if err != nil {
+		shared.LogLevel("debug", "no log excerpt to attach for %s: %v", tc.Name, err)
+		return ""
+	}
+
+	return path
+}
@@ -110,10 +110,11 @@ func collectCaseIDs(pd *processedTestdata) []int64 {
 // createTestResult receives the the createResultRequest and sends the request to create a test result in Qase.
 func (c Client) createTestResult(ctx context.Context, req *createResultRequest) error {
 	qaseRequest := qaseclient.ResultCreate{
-		CaseId:  req.caseID,
-		Status:  req.status,
-		Time:    req.time,
-		Comment: req.comment,
+		CaseId:      req.caseID,
+		Status:      req.status,
+		Time:        req.time,
+		Comment:     req.comment,
+		Attachments: req.attachments,
 	}
 
 	create := c.QaseAPI.ResultsAPI.CreateResult(ctx, req.projectID, req.runID).ResultCreate(qaseRequest)
@@ -136,10 +137,11 @@ func (c Client) createBulkTestResult(reqs []createResultRequest) error {
 	results := make([]qaseclient.ResultCreate, len(reqs))
 	for i, r := range reqs {
 		results[i] = qaseclient.ResultCreate{
-			CaseId:  r.caseID,
-			Status:  r.status,
-			Comment: r.comment,
-			Time:    r.time,
+			CaseId:      r.caseID,
+			Status:      r.status,
+			Comment:     r.comment,
+			Time:        r.time,
+			Attachments: r.attachments,
 		}
 	}
 
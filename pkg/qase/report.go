@@ -54,6 +54,9 @@ type createResultRequest struct {
 	caseID    *int64
 	time      qaseclient.NullableInt64
 	comment   qaseclient.NullableString
+	// attachmentPaths are local files uploaded to Qase before the result is created.
+	attachmentPaths []string
+	attachments     []string
 }
 
 func (c Client) ReportE2ETestRun(fileName, product, ciArch string) (int32, error) {
@@ -75,7 +78,14 @@ func (c Client) ReportE2ETestRun(fileName, product, ciArch string) (int32, error
 
 	tcs := testSuiteDetailsToTestCase(pd.testSummary)
 
-	resultReq := parseBulkResults(tcs, runID)
+	dir, cleanup := newAttachmentsDir()
+	defer cleanup()
+
+	resultReq := parseBulkResults(dir, tcs, runID)
+	for i := range resultReq {
+		c.attachFiles(c.Ctx, &resultReq[i])
+	}
+
 	if err := c.createBulkTestResult(resultReq); err != nil {
 		return 0, fmt.Errorf("error creating bulk test result: %w", err)
 	}
@@ -107,12 +117,31 @@ func (c Client) SpecReportTestResults(ctx context.Context, cluster *shared.Clust
 
 	tcs, _ := specReportToTestCase(report)
 	request := parseResults(cluster, tcs, reportSummary, &req)
+	dir, cleanup := newAttachmentsDir()
+	defer cleanup()
+
+	request.attachmentPaths = specReportAttachments(dir, cluster, report)
+	c.attachFiles(ctx, request)
 
 	if err := c.createTestResult(ctx, request); err != nil {
 		shared.LogLevel("error", "failed to create test result: %w\n", err)
 	}
 }
 
+// attachFiles uploads the request attachment files to Qase and sets the resulting hashes on the request.
+// Upload failures are logged only, so the result itself is still reported.
+func (c Client) attachFiles(ctx context.Context, req *createResultRequest) {
+	if len(req.attachmentPaths) == 0 {
+		return
+	}
+
+	hashes, err := c.uploadAttachments(ctx, req.attachmentPaths)
+	if err != nil {
+		shared.LogLevel("warn", "failed to upload attachments for run %d: %v", req.runID, err)
+	}
+	req.attachments = hashes
+}
+
 // validateQaseIDs validates the Qase Run ID and Test Case ID and returns as int32 and int64 respectively.
 func validateQaseIDs() (runID int32, tcID *int64, err error) {
 	if projectID == "" {
@@ -238,7 +267,7 @@ func testSuiteDetailsToTestCase(testOverview []testOverview) []TestCase {
 	return testCases
 }
 
-func parseBulkResults(testCases []TestCase, runID int32) []createResultRequest {
+func parseBulkResults(dir string, testCases []TestCase, runID int32) []createResultRequest {
 	caseGroups := make(map[int64][]TestCase)
 	for _, tc := range testCases {
 		if tc.CaseID <= 0 {
@@ -256,11 +285,16 @@ func parseBulkResults(testCases []TestCase, runID int32) []createResultRequest {
 		var commentBuilder strings.Builder
 		commentBuilder.WriteString("Version Tested: Latest master commit, see link above on description!\n\n")
 
+		var attachmentPaths []string
+
 		for _, tc := range group {
 			totalElapsed += tc.Elapsed
 
 			if tc.Status == failStatus {
 				finalStatus = failStatus
+				if path := failedCaseAttachment(dir, &tc); path != "" {
+					attachmentPaths = append(attachmentPaths, path)
+				}
 				commentBuilder.WriteString(fmt.Sprintf(
 					"---\n**FAILED Sub-test:** %s\n\n",
 					tc.Name,
@@ -280,12 +314,13 @@ func parseBulkResults(testCases []TestCase, runID int32) []createResultRequest {
 		}
 
 		req := createResultRequest{
-			projectID: projectID,
-			runID:     runID,
-			caseID:    newInt64(cid),
-			time:      newNullableInt64(totalElapsed),
-			status:    finalStatus,
-			comment:   newNullableString(commentBuilder.String()),
+			projectID:       projectID,
+			runID:           runID,
+			caseID:          newInt64(cid),
+			time:            newNullableInt64(totalElapsed),
+			status:          finalStatus,
+			comment:         newNullableString(commentBuilder.String()),
+			attachmentPaths: attachmentPaths,
 		}
 
 		reqs = append(reqs, req)
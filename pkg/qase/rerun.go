@@ -0,0 +1,107 @@
+package qase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RerunDiff compares the failed test directories of the original run with the results of a rerun.
+type RerunDiff struct {
+	NowPassing   []string
+	StillFailing []string
+	NewlyFailing []string
+	// NoResults are rerun tests that produced no results in the rerun log.
+	NoResults []string
+	// Remaining are the failures left after the rerun, including the ones that were not rerun.
+	Remaining []string
+}
+
+// DiffRerun processes the rerun go test JSON log and compares it with the failed tests of the original run.
+// Tests are compared by test directory, the same names used by the rerun poller and run_tests.sh.
+func DiffRerun(fileName, product, ciArch, baseDir string, previousFailed, rerunTests []string) (*RerunDiff, error) {
+	pd, err := processTestData(fileName, product, ciArch)
+	if err != nil {
+		return nil, fmt.Errorf("error processing rerun test data: %w", err)
+	}
+
+	return diffRerun(previousFailed, rerunTests, getTestDirs(pd, baseDir), getFailedTestDirs(pd, baseDir)), nil
+}
+
+// diffRerun compares the failed tests of the original run with the tests that ran and failed in the rerun.
+func diffRerun(previousFailed, rerunTests, ran, failed []string) *RerunDiff {
+	diff := &RerunDiff{}
+	for _, test := range previousFailed {
+		switch {
+		case containsFold(failed, test):
+			diff.StillFailing = append(diff.StillFailing, test)
+		case containsFold(ran, test):
+			diff.NowPassing = append(diff.NowPassing, test)
+		case containsFold(rerunTests, test):
+			diff.NoResults = append(diff.NoResults, test)
+		}
+	}
+
+	for _, test := range failed {
+		if !containsFold(previousFailed, test) {
+			diff.NewlyFailing = append(diff.NewlyFailing, test)
+		}
+	}
+
+	for _, test := range previousFailed {
+		if !containsFold(diff.NowPassing, test) {
+			diff.Remaining = append(diff.Remaining, test)
+		}
+	}
+	diff.Remaining = append(diff.Remaining, diff.NewlyFailing...)
+
+	for _, list := range [][]string{diff.NowPassing, diff.StillFailing, diff.NewlyFailing, diff.NoResults, diff.Remaining} {
+		slices.Sort(list)
+	}
+
+	return diff
+}
+
+// AddRunResults processes the rerun log and adds its results to an existing Qase run.
+func (c Client) AddRunResults(fileName, product, ciArch string, runID int32) error {
+	pd, err := processTestData(fileName, product, ciArch)
+	if err != nil {
+		return fmt.Errorf("error processing test data: %w", err)
+	}
+
+	tcs := testSuiteDetailsToTestCase(pd.testSummary)
+
+	dir, cleanup := newAttachmentsDir()
+	defer cleanup()
+
+	resultReq := parseBulkResults(dir, tcs, runID)
+	for i := range resultReq {
+		c.attachFiles(c.Ctx, &resultReq[i])
+	}
+
+	if err := c.createBulkTestResult(resultReq); err != nil {
+		return fmt.Errorf("error adding rerun results to run %d: %w", runID, err)
+	}
+
+	return nil
+}
+
+// getTestDirs maps every suite found in the log to its test directory.
+func getTestDirs(pd *processedTestdata, baseDir string) []string {
+	testDirs := readTestDirs(baseDir)
+
+	var result []string
+	for _, suite := range pd.testSuiteSummary {
+		if dir := mapTestSuiteToDir(suite.testSuiteName, testDirs); dir != "" && !slices.Contains(result, dir) {
+			result = append(result, dir)
+		}
+	}
+
+	return result
+}
+
+func containsFold(list []string, name string) bool {
+	return slices.ContainsFunc(list, func(s string) bool {
+		return strings.EqualFold(s, name)
+	})
+}
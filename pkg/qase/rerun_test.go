@@ -0,0 +1,110 @@
+package qase
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffRerun(t *testing.T) {
+	tests := []struct {
+		name           string
+		previousFailed []string
+		rerunTests     []string
+		ran            []string
+		failed         []string
+		want           RerunDiff
+	}{
+		{
+			name:           "all fixed",
+			previousFailed: []string{"validatecluster", "certrotate"},
+			rerunTests:     []string{"validatecluster", "certrotate"},
+			ran:            []string{"validatecluster", "certrotate"},
+			want:           RerunDiff{NowPassing: []string{"certrotate", "validatecluster"}},
+		},
+		{
+			name:           "still failing",
+			previousFailed: []string{"validatecluster", "certrotate"},
+			rerunTests:     []string{"validatecluster", "certrotate"},
+			ran:            []string{"validatecluster", "certrotate"},
+			failed:         []string{"certrotate"},
+			want: RerunDiff{
+				NowPassing:   []string{"validatecluster"},
+				StillFailing: []string{"certrotate"},
+				Remaining:    []string{"certrotate"},
+			},
+		},
+		{
+			name:           "newly failing suite rerun though it passed",
+			previousFailed: []string{"validatecluster"},
+			rerunTests:     []string{"validatecluster", "secretsencrypt"},
+			ran:            []string{"validatecluster", "secretsencrypt"},
+			failed:         []string{"secretsencrypt"},
+			want: RerunDiff{
+				NowPassing:   []string{"validatecluster"},
+				NewlyFailing: []string{"secretsencrypt"},
+				Remaining:    []string{"secretsencrypt"},
+			},
+		},
+		{
+			name:           "rerun test without results",
+			previousFailed: []string{"validatecluster", "certrotate"},
+			rerunTests:     []string{"validatecluster", "certrotate"},
+			ran:            []string{"validatecluster"},
+			want: RerunDiff{
+				NowPassing: []string{"validatecluster"},
+				NoResults:  []string{"certrotate"},
+				Remaining:  []string{"certrotate"},
+			},
+		},
+		{
+			name:           "failures not rerun remain",
+			previousFailed: []string{"validatecluster", "certrotate", "upgradecluster"},
+			rerunTests:     []string{"certrotate"},
+			ran:            []string{"certrotate"},
+			want: RerunDiff{
+				NowPassing: []string{"certrotate"},
+				Remaining:  []string{"upgradecluster", "validatecluster"},
+			},
+		},
+		{
+			name:           "names compared case-insensitively",
+			previousFailed: []string{"upgradeCluster", "secretsEncrypt"},
+			rerunTests:     []string{"upgradeCluster", "secretsEncrypt"},
+			ran:            []string{"upgradecluster", "secretsencrypt"},
+			failed:         []string{"secretsencrypt"},
+			want: RerunDiff{
+				NowPassing:   []string{"upgradeCluster"},
+				StillFailing: []string{"secretsEncrypt"},
+				Remaining:    []string{"secretsEncrypt"},
+			},
+		},
+		{
+			name:       "no previous failures",
+			rerunTests: []string{"validatecluster"},
+			ran:        []string{"validatecluster"},
+			failed:     []string{"validatecluster"},
+			want:       RerunDiff{NewlyFailing: []string{"validatecluster"}, Remaining: []string{"validatecluster"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffRerun(tt.previousFailed, tt.rerunTests, tt.ran, tt.failed)
+
+			for _, field := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"now passing", got.NowPassing, tt.want.NowPassing},
+				{"still failing", got.StillFailing, tt.want.StillFailing},
+				{"newly failing", got.NewlyFailing, tt.want.NewlyFailing},
+				{"no results", got.NoResults, tt.want.NoResults},
+				{"remaining", got.Remaining, tt.want.Remaining},
+			} {
+				if !slices.Equal(field.got, field.want) {
+					t.Errorf("%s: expected %v, got %v", field.name, field.want, field.got)
+				}
+			}
+		})
+	}
+}
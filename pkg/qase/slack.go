@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rancher/distros-test-framework/shared"
@@ -126,7 +127,7 @@ func postSlackResults(sc *slackClient, pd *processedTestdata, product, ciArch, b
 
 	if baseDir != "" && (threadTS != "" || sc.dryRun) {
 		if parentThreadTS == "" && !sc.dryRun {
-			if err := saveRerunState(baseDir, sc.channelID, threadTS, product, failedDirs); err != nil {
+			if err := saveRerunState(baseDir, sc.channelID, threadTS, product, failedDirs, runID); err != nil {
 				shared.LogLevel("warn", "Failed to save rerun state: %v", err)
 			}
 		} else if !sc.dryRun {
@@ -256,7 +257,8 @@ func mapTestSuiteToDir(suiteName string, testDirs []string) string {
 	return ""
 }
 
-func getFailedTestDirs(pd *processedTestdata, baseDir string) []string {
+// readTestDirs reads the test directories executed in the run from the .test-dirs.txt report file.
+func readTestDirs(baseDir string) []string {
 	var testDirs []string
 	testDirsFile := filepath.Join(baseDir, "report", ".test-dirs.txt")
 	if data, err := os.ReadFile(testDirsFile); err == nil {
@@ -268,6 +270,11 @@ func getFailedTestDirs(pd *processedTestdata, baseDir string) []string {
 		}
 	}
 
+	return testDirs
+}
+
+func getFailedTestDirs(pd *processedTestdata, baseDir string) []string {
+	testDirs := readTestDirs(baseDir)
 	if len(testDirs) == 0 {
 		return nil
 	}
@@ -371,7 +378,8 @@ func (s *slackClient) PostTestResults(
 				}
 			}
 			if len(testNames) > 0 {
-				actionText = ":repeat: *To rerun failed tests*, reply with: `rerun: failed`"
+				actionText = ":repeat: *To rerun failed tests*, reply with: `rerun: failed`" +
+					" (reply with `help` for more options)"
 			}
 		}
 		if actionText != "" {
@@ -561,7 +569,7 @@ func (s *slackClient) sendMessage(msg slackMessage) (string, error) {
 	return postResp.TS, nil
 }
 
-func saveRerunState(baseDir, channelID, threadTS, product string, failedTests []string) error {
+func saveRerunState(baseDir, channelID, threadTS, product string, failedTests []string, runID int32) error {
 	state := map[string]interface{}{
 		"thread_ts":         threadTS,
 		"channel_id":        channelID,
@@ -570,6 +578,10 @@ func saveRerunState(baseDir, channelID, threadTS, product string, failedTests []
 		"posted_at":         time.Now().Format(time.RFC3339),
 		"failed_tests":      failedTests,
 	}
+	// the original Qase run is kept so rerun results can be added to it.
+	if runID > 0 {
+		state["qase_run_id"] = runID
+	}
 
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
@@ -577,13 +589,10 @@ func saveRerunState(baseDir, channelID, threadTS, product string, failedTests []
 	}
 
 	statePath := filepath.Join(baseDir, "report", ".rerun-state.json")
-	tmpPath := statePath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write temp state file: %w", err)
-	}
-	if err := os.Rename(tmpPath, statePath); err != nil {
-		_ = os.Remove(tmpPath)
-		return fmt.Errorf("failed to rename state file: %w", err)
+	if err := withRerunStateLock(baseDir, func() error {
+		return writeRerunState(statePath, data)
+	}); err != nil {
+		return err
 	}
 
 	shared.LogLevel("info", "Saved rerun state: thread_ts=%s, failed_tests=%v", threadTS, failedTests)
@@ -594,26 +603,55 @@ func saveRerunState(baseDir, channelID, threadTS, product string, failedTests []
 func updateFailedTests(baseDir string, failedTests []string) error {
 	statePath := filepath.Join(baseDir, "report", ".rerun-state.json")
 
-	data, err := os.ReadFile(statePath)
-	if err != nil {
-		return fmt.Errorf("failed to read state file: %w", err)
-	}
+	if err := withRerunStateLock(baseDir, func() error {
+		data, err := os.ReadFile(statePath)
+		if err != nil {
+			return fmt.Errorf("failed to read state file: %w", err)
+		}
 
-	var state map[string]interface{}
-	if err := json.Unmarshal(data, &state); err != nil {
-		return fmt.Errorf("failed to parse state file: %w", err)
+		var state map[string]interface{}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to parse state file: %w", err)
+		}
+
+		state["failed_tests"] = failedTests
+		state["posted_at"] = time.Now().Format(time.RFC3339)
+
+		newData, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal state: %w", err)
+		}
+
+		return writeRerunState(statePath, newData)
+	}); err != nil {
+		return err
 	}
 
-	state["failed_tests"] = failedTests
-	state["posted_at"] = time.Now().Format(time.RFC3339)
+	shared.LogLevel("info", "Updated failed_tests in state: %v", failedTests)
+
+	return nil
+}
 
-	newData, err := json.MarshalIndent(state, "", "  ")
+// withRerunStateLock calls fn holding the file lock the rerun poller takes around its queue and state writes,
+// so a new run never races with the poller updating the state file.
+func withRerunStateLock(baseDir string, fn func() error) error {
+	lock, err := os.OpenFile(filepath.Join(baseDir, "report", ".rerun-queue.lock"), os.O_CREATE|os.O_RDWR, 0o600)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		return fmt.Errorf("failed to open rerun state lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock rerun state: %w", err)
 	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	return fn()
+}
 
+func writeRerunState(statePath string, data []byte) error {
 	tmpPath := statePath + ".tmp"
-	if err := os.WriteFile(tmpPath, newData, 0o600); err != nil {
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
 		return fmt.Errorf("failed to write temp state file: %w", err)
 	}
 	if err := os.Rename(tmpPath, statePath); err != nil {
@@ -621,7 +659,5 @@ func updateFailedTests(baseDir string, failedTests []string) error {
 		return fmt.Errorf("failed to rename state file: %w", err)
 	}
 
-	shared.LogLevel("info", "Updated failed_tests in state: %v", failedTests)
-
 	return nil
 }
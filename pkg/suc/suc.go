@@ -0,0 +1,68 @@
+// Package suc vendors the system-upgrade-controller release manifests, one directory per SUC version under manifests/.
+//
+// To add a version, download system-upgrade-controller.yaml and crd.yaml from the release into manifests/<version>.
+package suc
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// DefaultVersion is the SUC version used when none is requested.
+const DefaultVersion = "v0.15.2"
+
+const (
+	// ControllerManifest is the file name of the controller manifest in every version directory.
+	ControllerManifest = "system-upgrade-controller.yaml"
+	// CRDManifest is the file name of the Plan CRD manifest in every version directory.
+	CRDManifest = "crd.yaml"
+)
+
+//go:embed manifests
+var manifests embed.FS
+
+// Versions returns the vendored SUC versions.
+func Versions() []string {
+	entries, err := fs.ReadDir(manifests, "manifests")
+	if err != nil {
+		return nil
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	slices.Sort(versions)
+
+	return versions
+}
+
+// WriteManifests writes the CRD and controller manifests of the version to dir and returns their paths,
+// in the order they should be applied.
+func WriteManifests(version, dir string) ([]string, error) {
+	if !slices.Contains(Versions(), version) {
+		return nil, fmt.Errorf("SUC version %s is not vendored, available versions: %v", version, Versions())
+	}
+
+	paths := make([]string, 0, 2)
+	for _, name := range []string{CRDManifest, ControllerManifest} {
+		content, err := manifests.ReadFile("manifests/" + version + "/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of SUC %s: %w", name, version, err)
+		}
+
+		path := filepath.Join(dir, "suc-"+version+"-"+name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
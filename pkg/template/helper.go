@@ -112,6 +112,9 @@ func addTestCaseMap(cluster *shared.Cluster) map[string]testCase {
 		"TestClusterReset": func(applyWorkload, deleteWorkload bool) {
 			testcase.TestClusterReset(cluster)
 		},
+		"TestEtcdSnapshot": func(applyWorkload, deleteWorkload bool) {
+			testcase.TestEtcdSnapshot(cluster)
+		},
 	}
 }
 
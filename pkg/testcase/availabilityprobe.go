@@ -0,0 +1,316 @@
+package testcase
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/distros-test-framework/pkg/customflag"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	availabilityProbeWorkload = "availability-probe.yaml"
+	availabilityProbeInterval = 10 * time.Second
+	// availabilityProbeLogOverlap is read again on every collection, covering clock skew between the host and the nodes.
+	availabilityProbeLogOverlap = 30 * time.Second
+	// availabilityProbeMaxGap is the longest expected time between two samples, a client loop sleeps 1s
+	// and waits at most 2s for each check. Seconds of longer gaps are counted as failed samples.
+	availabilityProbeMaxGap = 6
+)
+
+// AvailabilityProbe collects the results of the in-cluster probe clients and the node state while an upgrade runs.
+type AvailabilityProbe struct {
+	mu          sync.Mutex
+	start       time.Time
+	collectedAt time.Time
+	samples     map[string]probeSample
+	nodes       map[string]*NodeTiming
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+type probeSample struct {
+	ts   int64
+	http bool
+	dns  bool
+}
+
+// NodeTiming is the state of a node observed during the upgrade, durations are relative to the probe start.
+type NodeTiming struct {
+	Name          string
+	FromVersion   string
+	ToVersion     string
+	UpgradedAfter time.Duration
+	NotReady      time.Duration
+	notReadySince time.Time
+}
+
+// AvailabilityReport summarizes the workload availability during the upgrade.
+type AvailabilityReport struct {
+	Duration        time.Duration
+	Samples         int
+	MissingSamples  int
+	HTTPSuccessRate float64
+	DNSSuccessRate  float64
+	LongestOutage   time.Duration
+	Nodes           []NodeTiming
+}
+
+func (r AvailabilityReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d samples (%d missing) in %s, http %.2f%%, dns %.2f%%, longest outage %s\n",
+		r.Samples, r.MissingSamples, r.Duration.Round(time.Second), r.HTTPSuccessRate, r.DNSSuccessRate,
+		r.LongestOutage))
+	for _, node := range r.Nodes {
+		upgraded := "not upgraded"
+		if node.UpgradedAfter > 0 {
+			upgraded = fmt.Sprintf("%s -> %s after %s", node.FromVersion, node.ToVersion, node.UpgradedAfter.Round(time.Second))
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s, not ready for %s\n", node.Name, upgraded, node.NotReady.Round(time.Second)))
+	}
+
+	return sb.String()
+}
+
+// StartAvailabilityProbe deploys the probe server and clients and collects their results in the background
+// until Stop is called.
+func StartAvailabilityProbe() *AvailabilityProbe {
+	workloadErr := shared.ManageWorkload("apply", availabilityProbeWorkload)
+	Expect(workloadErr).NotTo(HaveOccurred(), "availability probe manifest not deployed")
+
+	Eventually(func(g Gomega) {
+		cmd := "kubectl rollout status deployment/probe-server deployment/probe-client -n availability-probe " +
+			"--timeout=10s --kubeconfig=" + shared.KubeConfigFile
+		_, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+	}, "300s", "10s").Should(Succeed(), "availability probe not ready")
+
+	now := time.Now()
+	p := &AvailabilityProbe{
+		start:       now,
+		collectedAt: now,
+		samples:     make(map[string]probeSample),
+		nodes:       make(map[string]*NodeTiming),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	p.collect()
+
+	go p.run()
+	shared.LogLevel("info", "Availability probe started")
+
+	return p
+}
+
+// Stop stops collecting, removes the probe workload and returns the report.
+func (p *AvailabilityProbe) Stop() AvailabilityReport {
+	close(p.stop)
+	<-p.done
+	p.collect()
+
+	workloadErr := shared.ManageWorkload("delete", availabilityProbeWorkload)
+	if workloadErr != nil {
+		shared.LogLevel("warn", "availability probe manifest not deleted: %v", workloadErr)
+	}
+
+	return p.report()
+}
+
+// TestUpgradeAvailability stops the probe and validates the availability against the configured thresholds.
+func TestUpgradeAvailability(probe *AvailabilityProbe, flags *customflag.FlagConfig) {
+	Expect(probe).NotTo(BeNil(), "availability probe was not started")
+
+	report := probe.Stop()
+	shared.LogLevel("info", "Availability during upgrade: %s", report)
+	AddReportEntry("availability", report)
+
+	Expect(report.Samples).To(BeNumerically(">", 0), "no availability samples collected")
+	Expect(report.HTTPSuccessRate).To(BeNumerically(">=", flags.AvailabilityProbe.MinSuccessRate),
+		"http success rate below threshold")
+	Expect(report.DNSSuccessRate).To(BeNumerically(">=", flags.AvailabilityProbe.MinSuccessRate),
+		"dns success rate below threshold")
+	Expect(report.LongestOutage).To(BeNumerically("<=", flags.AvailabilityProbe.MaxOutage),
+		"longest outage above threshold")
+}
+
+func (p *AvailabilityProbe) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(availabilityProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.collect()
+		}
+	}
+}
+
+// collect reads the client logs written since the last successful collection and the node state,
+// errors are expected while the API server restarts and the next run catches up.
+func (p *AvailabilityProbe) collect() {
+	p.mu.Lock()
+	since := p.collectedAt.Add(-availabilityProbeLogOverlap)
+	p.mu.Unlock()
+
+	started := time.Now()
+	logsCmd := "kubectl logs -n availability-probe -l app=probe-client --prefix --tail=-1" +
+		" --since-time=" + since.UTC().Format(time.RFC3339) +
+		" --max-log-requests=10 --kubeconfig=" + shared.KubeConfigFile
+	logs, logsErr := shared.RunCommandHost(logsCmd)
+
+	nodes, nodesErr := shared.GetNodes(false)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if logsErr == nil {
+		p.addSamples(logs)
+		p.collectedAt = started
+	} else {
+		shared.LogLevel("debug", "availability probe logs not collected, retrying from %s: %v", since, logsErr)
+	}
+	if nodesErr == nil {
+		p.addNodes(nodes)
+	}
+}
+
+// addSamples parses the "[pod/<name>/client] probe <ts> <http> <dns>" log lines.
+func (p *AvailabilityProbe) addSamples(logs string) {
+	for _, line := range strings.Split(logs, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[1] != "probe" {
+			continue
+		}
+
+		ts, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || ts < p.start.Unix() {
+			continue
+		}
+
+		p.samples[fields[0]+fields[2]] = probeSample{ts: ts, http: fields[3] == "1", dns: fields[4] == "1"}
+	}
+}
+
+func (p *AvailabilityProbe) addNodes(nodes []shared.Node) {
+	now := time.Now()
+	for _, node := range nodes {
+		timing, found := p.nodes[node.Name]
+		if !found {
+			timing = &NodeTiming{Name: node.Name, FromVersion: node.Version}
+			p.nodes[node.Name] = timing
+		}
+
+		if timing.UpgradedAfter == 0 && node.Version != timing.FromVersion {
+			timing.ToVersion = node.Version
+			timing.UpgradedAfter = now.Sub(p.start)
+		}
+
+		ready := strings.HasPrefix(node.Status, "Ready")
+		switch {
+		case !ready && timing.notReadySince.IsZero():
+			timing.notReadySince = now
+		case ready && !timing.notReadySince.IsZero():
+			timing.NotReady += now.Sub(timing.notReadySince)
+			timing.notReadySince = time.Time{}
+		}
+	}
+}
+
+// missingSamples returns a failed sample for every second of the gaps longer than availabilityProbeMaxGap
+// between the samples of all clients from start to end, e.g. when the clients were evicted or their logs were lost.
+func missingSamples(samples []probeSample, start, end int64) []probeSample {
+	seconds := []int64{start, end}
+	for _, sample := range samples {
+		seconds = append(seconds, sample.ts)
+	}
+	slices.Sort(seconds)
+	seconds = slices.Compact(seconds)
+
+	var missing []probeSample
+	for i := 1; i < len(seconds); i++ {
+		if seconds[i]-seconds[i-1] <= availabilityProbeMaxGap {
+			continue
+		}
+		for ts := seconds[i-1] + 1; ts < seconds[i]; ts++ {
+			missing = append(missing, probeSample{ts: ts})
+		}
+	}
+
+	return missing
+}
+
+func (p *AvailabilityProbe) report() AvailabilityReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	samples := make([]probeSample, 0, len(p.samples))
+	for _, sample := range p.samples {
+		samples = append(samples, sample)
+	}
+	missing := missingSamples(samples, p.start.Unix(), p.collectedAt.Unix())
+	samples = append(samples, missing...)
+
+	report := AvailabilityReport{Duration: time.Since(p.start), Samples: len(samples), MissingSamples: len(missing)}
+
+	// a second is down when every sample taken in it failed.
+	up := make(map[int64]bool)
+	var httpOK, dnsOK int
+	for _, sample := range samples {
+		if sample.http {
+			httpOK++
+		}
+		if sample.dns {
+			dnsOK++
+		}
+		up[sample.ts] = up[sample.ts] || (sample.http && sample.dns)
+	}
+
+	if report.Samples > 0 {
+		report.HTTPSuccessRate = float64(httpOK) * 100 / float64(report.Samples)
+		report.DNSSuccessRate = float64(dnsOK) * 100 / float64(report.Samples)
+	}
+
+	seconds := make([]int64, 0, len(up))
+	for ts := range up {
+		seconds = append(seconds, ts)
+	}
+	slices.Sort(seconds)
+
+	// seconds without samples between two down seconds are counted as part of the outage.
+	var outageStart int64 = -1
+	for _, ts := range seconds {
+		if up[ts] {
+			outageStart = -1
+			continue
+		}
+		if outageStart < 0 {
+			outageStart = ts
+		}
+		if outage := time.Duration(ts-outageStart+1) * time.Second; outage > report.LongestOutage {
+			report.LongestOutage = outage
+		}
+	}
+
+	for _, timing := range p.nodes {
+		if !timing.notReadySince.IsZero() {
+			timing.NotReady += time.Since(timing.notReadySince)
+		}
+		report.Nodes = append(report.Nodes, *timing)
+	}
+	slices.SortFunc(report.Nodes, func(a, b NodeTiming) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return report
+}
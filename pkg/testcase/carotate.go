@@ -0,0 +1,281 @@
+package testcase
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/rancher/distros-test-framework/config"
+	"github.com/rancher/distros-test-framework/pkg/aws"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const caRotateDir = "/var/tmp/ca-rotate"
+
+// caNames are the cluster CAs under server/tls, every one is replaced by an intermediate of the new root.
+var caNames = []string{"server-ca", "client-ca", "request-header-ca", "etcd/server-ca", "etcd/peer-ca"}
+
+type certificateAuthority struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// TestCARotate generates a new root CA and one intermediate per cluster CA in Go, cross-signs the new root
+// with the current CAs and rotates to them with `certificate rotate-ca`, then restarts every node
+// and verifies every server and agent certificate chains to the new root.
+//
+// running it twice rotates from the default self-signed CAs to custom CAs and then between custom CAs.
+func TestCARotate(cluster *shared.Cluster) {
+	product := cluster.Config.Product
+	primaryIP := cluster.ServerIPs[0]
+
+	root := rotateCA(product, primaryIP)
+
+	for _, ip := range cluster.ServerIPs {
+		restartNodeService(cluster, server, ip)
+	}
+	for _, ip := range cluster.AgentIPs {
+		restartNodeService(cluster, agent, ip)
+	}
+
+	// the admin client certificate of the kubeconfig is signed by the previous client CA.
+	kubeconfigErr := shared.NewLocalKubeconfigFile(primaryIP, "ca-rotate", product, shared.KubeConfigFile)
+	Expect(kubeconfigErr).NotTo(HaveOccurred(), "error refreshing kubeconfig after CA rotation")
+
+	for _, ip := range slices.Concat(cluster.ServerIPs, cluster.AgentIPs) {
+		verifyCertificateChains(product, ip, root.cert)
+	}
+}
+
+// TestCABootstrap creates a fresh server, stages a Go generated root CA and its intermediates under server/tls
+// before the first start so the server bootstraps from the custom CAs, then rotates them with `certificate rotate-ca`.
+// The server is deleted at the end of the test.
+func TestCABootstrap(cluster *shared.Cluster, awsClient *aws.Client, cfg *config.Env) {
+	product := cluster.Config.Product
+	tlsDir := fmt.Sprintf("/var/lib/rancher/%s/server/tls", product)
+
+	_, ip := newInstance(awsClient, "-server-ca-bootstrap")
+	defer func() {
+		deleteErr := awsClient.DeleteInstance(ip)
+		Expect(deleteErr).NotTo(HaveOccurred(), "error deleting CA bootstrap server %s", ip)
+	}()
+
+	installErr := shared.InstallProduct(cluster, ip, cfg.InstallVersion)
+	Expect(installErr).NotTo(HaveOccurred(), "error installing %s on %s", product, ip)
+
+	root := newCertificateAuthority(fmt.Sprintf("distros-test-root-ca@%d", time.Now().Unix()), nil)
+
+	files := make(map[string][]byte)
+	for _, name := range caNames {
+		commonName := fmt.Sprintf("distros-test-%s@%d", path.Base(name), time.Now().Unix())
+		intermediate := newCertificateAuthority(commonName, root)
+
+		files[name+".crt"] = encodeCertificates(intermediate.cert, root.cert)
+		files[name+".key"] = encodeKey(intermediate.key)
+	}
+
+	serviceKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred(), "error generating service account key")
+	files["service.key"] = encodeKey(serviceKey)
+
+	stageFiles(ip, tlsDir, files)
+	shared.LogLevel("info", "Custom CA certificates %s staged on %s", root.cert.Subject.CommonName, ip)
+
+	startErr := shared.EnableAndStartService(cluster, ip, server)
+	Expect(startErr).NotTo(HaveOccurred(), "error starting %s on %s", product, ip)
+	verifyCertificateChains(product, ip, root.cert)
+
+	rotated := rotateCA(product, ip)
+	restartNodeService(cluster, server, ip)
+	verifyCertificateChains(product, ip, rotated.cert)
+}
+
+// rotateCA generates the new CAs, cross-signs the new root with the current CAs of the server
+// and runs `certificate rotate-ca` on it, returning the new root.
+func rotateCA(product, ip string) *certificateAuthority {
+	tlsDir := fmt.Sprintf("/var/lib/rancher/%s/server/tls", product)
+
+	root := newCertificateAuthority(fmt.Sprintf("distros-test-root-ca@%d", time.Now().Unix()), nil)
+
+	files := make(map[string][]byte)
+	for _, name := range caNames {
+		current := readCertificateAuthority(ip, tlsDir, name)
+		commonName := fmt.Sprintf("distros-test-%s@%d", path.Base(name), time.Now().Unix())
+		intermediate := newCertificateAuthority(commonName, root)
+
+		// the root cross-signed by the current CA lets the nodes that still trust it verify the new certificates.
+		crossSigned := signCertificate(root.cert, root.key.Public(), current)
+
+		files["tls/"+name+".crt"] = encodeCertificates(intermediate.cert, crossSigned, root.cert)
+		files["tls/"+name+".key"] = encodeKey(intermediate.key)
+	}
+
+	serviceKey, err := shared.RunCommandOnNode("sudo cat "+tlsDir+"/service.key", ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading service.key")
+	files["tls/service.key"] = []byte(serviceKey + "\n")
+
+	_, err = shared.RunCommandOnNode("sudo rm -rf "+caRotateDir, ip)
+	Expect(err).NotTo(HaveOccurred(), "error removing %s", caRotateDir)
+	stageFiles(ip, caRotateDir, files)
+
+	productCmd, err := shared.FindPath(product, ip)
+	Expect(err).NotTo(HaveOccurred())
+	rotateCmd := fmt.Sprintf("sudo %s certificate rotate-ca --path=%s", productCmd, caRotateDir)
+	res, err := shared.RunCommandOnNode(rotateCmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "error rotating CA certificates: %s", res)
+	shared.LogLevel("info", "CA certificates rotated to %s: %s", root.cert.Subject.CommonName, res)
+
+	_, err = shared.RunCommandOnNode("sudo rm -rf "+caRotateDir, ip)
+	Expect(err).NotTo(HaveOccurred(), "error removing %s", caRotateDir)
+
+	return root
+}
+
+// newCertificateAuthority returns a self-signed CA when parent is nil, or an intermediate CA signed by parent.
+func newCertificateAuthority(commonName string, parent *certificateAuthority) *certificateAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred(), "error generating CA key")
+
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	ca := &certificateAuthority{key: key}
+	if parent == nil {
+		parent = &certificateAuthority{cert: template, key: key}
+	}
+	ca.cert = signCertificate(template, key.Public(), parent)
+
+	return ca
+}
+
+// signCertificate signs the template for the public key with the CA.
+func signCertificate(template *x509.Certificate, pub crypto.PublicKey, ca *certificateAuthority) *x509.Certificate {
+	cert := *template
+	cert.SerialNumber = newSerialNumber()
+
+	der, err := x509.CreateCertificate(rand.Reader, &cert, ca.cert, pub, ca.key)
+	Expect(err).NotTo(HaveOccurred(), "error signing %s with %s", template.Subject.CommonName, ca.cert.Subject.CommonName)
+
+	signed, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return signed
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	Expect(err).NotTo(HaveOccurred(), "error generating serial number")
+
+	return serial
+}
+
+// readCertificateAuthority reads the current CA certificate and key from the server.
+func readCertificateAuthority(ip, tlsDir, name string) *certificateAuthority {
+	certPEM, err := shared.RunCommandOnNode(fmt.Sprintf("sudo cat %s/%s.crt", tlsDir, name), ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading %s.crt", name)
+	keyPEM, err := shared.RunCommandOnNode(fmt.Sprintf("sudo cat %s/%s.key", tlsDir, name), ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading %s.key", name)
+
+	certs := shared.ParseCertificates([]byte(certPEM))
+	Expect(certs).NotTo(BeEmpty(), "no certificate in %s.crt", name)
+
+	block, _ := pem.Decode([]byte(keyPEM))
+	Expect(block).NotTo(BeNil(), "no key in %s.key", name)
+
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	Expect(err).NotTo(HaveOccurred(), "error parsing %s.key", name)
+
+	signer, ok := key.(crypto.Signer)
+	Expect(ok).To(BeTrue(), "%s.key is not a signing key", name)
+
+	return &certificateAuthority{cert: certs[0], key: signer}
+}
+
+// stageFiles writes the files under dir on the node, creating their parent directories.
+func stageFiles(ip, dir string, files map[string][]byte) {
+	for name, content := range files {
+		cmd := fmt.Sprintf("sudo mkdir -p %[2]s && echo %[1]s | base64 -d | sudo tee %[3]s > /dev/null",
+			base64.StdEncoding.EncodeToString(content), path.Dir(path.Join(dir, name)), path.Join(dir, name))
+		_, err := shared.RunCommandOnNode(cmd, ip)
+		Expect(err).NotTo(HaveOccurred(), "error writing %s on %s", name, ip)
+	}
+}
+
+// verifyCertificateChains parses every server and agent certificate of the node and verifies it chains to root,
+// using the other certificates of its file as intermediates.
+func verifyCertificateChains(product, ip string, root *x509.Certificate) {
+	Eventually(func(g Gomega) {
+		files, err := shared.ReadNodeCertificates(product, ip)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		roots := x509.NewCertPool()
+		roots.AddCert(root)
+
+		// leaf files do not always carry their chain, the CAs of every file on the node are used as intermediates.
+		intermediates := x509.NewCertPool()
+		for _, certs := range files {
+			for _, cert := range certs {
+				if cert.IsCA {
+					intermediates.AddCert(cert)
+				}
+			}
+		}
+
+		var verified int
+		for name, certs := range files {
+			_, verifyErr := certs[0].Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			})
+			g.Expect(verifyErr).NotTo(HaveOccurred(), "%s on %s does not chain to %s", name, ip, root.Subject.CommonName)
+			verified++
+		}
+		g.Expect(verified).To(BeNumerically(">", 0), "no certificates found on %s", ip)
+		shared.LogLevel("info", "%d certificates on %s chain to %s", verified, ip, root.Subject.CommonName)
+	}, "300s", "15s").Should(Succeed(), "certificates on %s not signed by the new CA", ip)
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var encoded []byte
+	for _, cert := range certs {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	return encoded
+}
+
+func encodeKey(key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).NotTo(HaveOccurred(), "error encoding key")
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
@@ -2,6 +2,7 @@ package testcase
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/rancher/distros-test-framework/shared"
@@ -9,7 +10,27 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// serverLeafCertificates are the leaf certificates under server/tls renewed on every server by `certificate rotate`,
+// controlPlaneLeafCertificates and etcdLeafCertificates only on the servers with the role.
+var (
+	serverLeafCertificates = []string{
+		"client-admin.crt", "client-auth-proxy.crt", "client-controller.crt", "client-<product>-controller.crt",
+		"client-kube-proxy.crt", "client-scheduler.crt",
+	}
+	controlPlaneLeafCertificates = []string{"client-kube-apiserver.crt", "serving-kube-apiserver.crt", "etcd/client.crt"}
+	etcdLeafCertificates         = []string{"etcd/server-client.crt", "etcd/peer-server-client.crt"}
+)
+
+// agentLeafCertificates are the leaf certificates under agent renewed on every node when it restarts.
+var agentLeafCertificates = []string{
+	"client-kubelet.crt", "client-kube-proxy.crt", "client-<product>-controller.crt", "serving-kubelet.crt",
+}
+
 func TestCertRotate(cluster *shared.Cluster) {
+	ips := slices.Concat(cluster.ServerIPs, cluster.AgentIPs)
+	before, err := shared.CollectCertificates(cluster.Config.Product, ips)
+	Expect(err).NotTo(HaveOccurred(), "error collecting certificates before rotation")
+
 	ms := shared.NewManageService(10, 10)
 	certRotate(ms, cluster.Config.Product, cluster.ServerIPs)
 
@@ -35,6 +56,76 @@ func TestCertRotate(cluster *shared.Cluster) {
 	}
 
 	verifyTLSDirContent(cluster.Config.Product, cluster.ServerIPs)
+
+	after, err := shared.CollectCertificates(cluster.Config.Product, ips)
+	Expect(err).NotTo(HaveOccurred(), "error collecting certificates after rotation")
+	shared.LogLevel("debug", "Certificates after rotation:\n%s", after)
+
+	for _, ip := range cluster.ServerIPs {
+		role, roleErr := shared.NodeRole(cluster.Config.Product, ip)
+		Expect(roleErr).NotTo(HaveOccurred(), "error getting role of %s", ip)
+
+		expected := expectedRotatedCertificates(cluster.Config.Product, &role)
+		verifyRotatedCertificates(cluster.Config.Product, ip, expected, before.Node(ip), after.Node(ip))
+	}
+	for _, ip := range cluster.AgentIPs {
+		expected := expectedRotatedCertificates(cluster.Config.Product, nil)
+		verifyRotatedCertificates(cluster.Config.Product, ip, expected, before.Node(ip), after.Node(ip))
+	}
+}
+
+// expectedRotatedCertificates returns the paths of the leaf certificates renewed on a server of the role,
+// or on an agent when role is nil.
+func expectedRotatedCertificates(product string, role *shared.NodeType) []string {
+	dataDir := "/var/lib/rancher/" + product
+
+	var files []string
+	if role != nil {
+		names := slices.Clone(serverLeafCertificates)
+		if role.HasControlPlane() {
+			names = append(names, controlPlaneLeafCertificates...)
+		}
+		if role.HasEtcd() {
+			names = append(names, etcdLeafCertificates...)
+		}
+		for _, name := range names {
+			files = append(files, dataDir+"/server/tls/"+strings.ReplaceAll(name, "<product>", product))
+		}
+	}
+	for _, name := range agentLeafCertificates {
+		files = append(files, dataDir+"/agent/"+strings.ReplaceAll(name, "<product>", product))
+	}
+
+	return files
+}
+
+// verifyRotatedCertificates verifies the NotAfter of every expected leaf certificate of the node advanced
+// and every CA certificate under server/tls is untouched.
+func verifyRotatedCertificates(product, ip string, expected []string, before, after map[string]shared.Certificate) {
+	tlsDir := fmt.Sprintf("/var/lib/rancher/%s/server/tls/", product)
+
+	for file, prev := range before {
+		if !strings.HasPrefix(file, tlsDir) || !prev.IsCA {
+			continue
+		}
+
+		cert, found := after[file]
+		Expect(found).To(BeTrue(), "CA %s missing on %s after rotation", file, ip)
+		Expect(cert.Serial).To(Equal(prev.Serial), "CA %s changed on %s", file, ip)
+		Expect(cert.NotAfter).To(Equal(prev.NotAfter), "CA %s expiry changed on %s", file, ip)
+	}
+
+	var renewed []string
+	for _, file := range expected {
+		prev, foundBefore := before[file]
+		cert, foundAfter := after[file]
+		if foundBefore && foundAfter && cert.NotAfter.After(prev.NotAfter) {
+			renewed = append(renewed, file)
+		}
+	}
+
+	Expect(renewed).To(ConsistOf(expected), "certificates not renewed on %s", ip)
+	shared.LogLevel("info", "%d certificates renewed and CAs untouched on %s", len(renewed), ip)
 }
 
 // certRotate Rotate certificate for etcd only and cp only nodes.
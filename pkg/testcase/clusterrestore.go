@@ -2,6 +2,7 @@ package testcase
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
@@ -21,7 +22,7 @@ func TestClusterRestore(cluster *shared.Cluster, awsClient *aws.Client, cfg *con
 	onDemandPath := s3Snapshot(cluster, awsClient, flags)
 	stopInstances(cluster, awsClient)
 
-	serverName, newServerIP := newInstance(awsClient)
+	serverName, newServerIP := newInstance(awsClient, "-server-fresh")
 
 	err := shared.InstallProduct(cluster, newServerIP, cfg.InstallVersion)
 	Expect(err).NotTo(HaveOccurred())
@@ -64,16 +65,62 @@ func takeS3Snapshot(cluster *shared.Cluster, flags *customflag.FlagConfig) {
 	productLocationCmd, findErr := shared.FindPath(cluster.Config.Product, cluster.ServerIPs[0])
 	Expect(findErr).NotTo(HaveOccurred())
 
-	takeSnapshotCmd := fmt.Sprintf("sudo %s etcd-snapshot save --s3 --s3-bucket=%s "+
-		"--s3-folder=%s --s3-region=%s --s3-access-key=%s --s3-secret-key=%s",
-		productLocationCmd, flags.S3Flags.Bucket, flags.S3Flags.Folder, cluster.Aws.Region,
-		cluster.Aws.AccessKeyID, cluster.Aws.SecretAccessKey)
+	takeSnapshotCmd := fmt.Sprintf("sudo %s etcd-snapshot save --s3%s",
+		productLocationCmd, s3SnapshotArgs(cluster, flags, cluster.ServerIPs[0], "s3"))
 	snapshotResponse, takeSnapshotErr := shared.RunCommandOnNode(takeSnapshotCmd, cluster.ServerIPs[0])
 	Expect(takeSnapshotErr).NotTo(HaveOccurred())
 
 	shared.LogLevel("info", "snapshot taken in s3: %s", snapshotResponse)
 }
 
+// s3SnapshotArgs returns the S3 flags with the given prefix, "s3" for etcd-snapshot and "etcd-s3" for server,
+// including the endpoint flags when an S3-compatible endpoint is set. The endpoint CA is copied to the node.
+func s3SnapshotArgs(cluster *shared.Cluster, flags *customflag.FlagConfig, ip, prefix string) string {
+	s3Flags := flags.S3Flags
+
+	region, accessKey, secretKey := cluster.Aws.Region, cluster.Aws.AccessKeyID, cluster.Aws.SecretAccessKey
+	if s3Flags.Region != "" {
+		region = s3Flags.Region
+	}
+	if s3Flags.AccessKey != "" && s3Flags.SecretKey != "" {
+		accessKey, secretKey = s3Flags.AccessKey, s3Flags.SecretKey
+	}
+
+	args := fmt.Sprintf(" --%[1]s-bucket=%[2]s --%[1]s-folder=%[3]s --%[1]s-region=%[4]s"+
+		" --%[1]s-access-key=%[5]s --%[1]s-secret-key=%[6]s",
+		prefix, s3Flags.Bucket, s3Flags.Folder, region, accessKey, secretKey)
+
+	if s3Flags.Endpoint == "" {
+		return args
+	}
+
+	// the product expects host[:port], plain http endpoints need the insecure flag.
+	endpoint := s3Flags.Endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+		if u.Scheme == "http" {
+			args += fmt.Sprintf(" --%s-insecure", prefix)
+		}
+	}
+	args += fmt.Sprintf(" --%s-endpoint=%s", prefix, endpoint)
+
+	if s3Flags.PathStyle {
+		args += fmt.Sprintf(" --%s-bucket-lookup-type=path", prefix)
+	}
+	if s3Flags.SkipTLSVerify {
+		args += fmt.Sprintf(" --%s-skip-ssl-verify", prefix)
+	}
+	if s3Flags.EndpointCA != "" {
+		remoteCA := "/tmp/s3-endpoint-ca.pem"
+		scpErr := shared.RunScp(cluster, ip, []string{s3Flags.EndpointCA}, []string{remoteCA})
+		Expect(scpErr).NotTo(HaveOccurred(), "error copying S3 endpoint CA to %s", ip)
+
+		args += fmt.Sprintf(" --%s-endpoint-ca=%s", prefix, remoteCA)
+	}
+
+	return args
+}
+
 func validateS3snapshot(awsClient *aws.Client, flags *customflag.FlagConfig, onDemandPath string) {
 	s3List, s3ListErr := awsClient.GetObjects(flags.S3Flags.Bucket)
 	Expect(s3ListErr).NotTo(HaveOccurred())
@@ -101,10 +148,10 @@ func stopInstances(cluster *shared.Cluster, ec2 *aws.Client) {
 	}
 }
 
-func newInstance(awsClient *aws.Client) (newServerName, newExternalIP string) {
+func newInstance(awsClient *aws.Client, suffix string) (newServerName, newExternalIP string) {
 	resourceName := os.Getenv("resource_name")
 	var serverName []string
-	serverName = append(serverName, resourceName+"-server-fresh")
+	serverName = append(serverName, resourceName+suffix)
 
 	externalServerIP, _, _, createErr := awsClient.CreateInstances(serverName...)
 	Expect(createErr).NotTo(HaveOccurred(), createErr)
@@ -126,16 +173,10 @@ func restoreS3Snapshot(
 	productLocationCmd, findErr := shared.FindPath(cluster.Config.Product, newClusterIP)
 	Expect(findErr).NotTo(HaveOccurred())
 
-	restoreCmd := fmt.Sprintf("sudo %s server --cluster-reset --etcd-s3 --cluster-reset-restore-path=%s"+
-		" --etcd-s3-bucket=%s --etcd-s3-folder=%s --etcd-s3-region=%s --etcd-s3-access-key=%s"+
-		" --etcd-s3-secret-key=%s --token=%s",
+	restoreCmd := fmt.Sprintf("sudo %s server --cluster-reset --etcd-s3 --cluster-reset-restore-path=%s%s --token=%s",
 		productLocationCmd,
 		onDemandPath,
-		flags.S3Flags.Bucket,
-		flags.S3Flags.Folder,
-		cluster.Aws.Region,
-		cluster.Aws.AccessKeyID,
-		cluster.Aws.SecretAccessKey,
+		s3SnapshotArgs(cluster, flags, newClusterIP, "etcd-s3"),
 		token)
 
 	restoreCmdRes, restoreCmdErr = shared.RunCommandOnNode(restoreCmd, newClusterIP)
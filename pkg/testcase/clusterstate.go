@@ -0,0 +1,34 @@
+package testcase
+
+import (
+	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// SnapshotClusterState takes a normalized snapshot of the cluster resources to diff after a disruptive operation.
+func SnapshotClusterState() *k8s.ClusterSnapshot {
+	k8sClient, err := k8s.AddClient()
+	Expect(err).NotTo(HaveOccurred(), "error creating k8s client")
+
+	snapshot, err := k8sClient.TakeClusterSnapshot()
+	Expect(err).NotTo(HaveOccurred(), "error taking cluster snapshot")
+	shared.LogLevel("info", "Cluster snapshot taken with %d objects", len(snapshot.Objects))
+
+	return snapshot
+}
+
+// TestClusterStateDiff takes a new snapshot and reports the objects that appeared, disappeared or changed since before.
+//
+// the client is created again since the kubeconfig can change during the operation, e.g. on a cluster restore.
+func TestClusterStateDiff(before *k8s.ClusterSnapshot) {
+	Expect(before).NotTo(BeNil(), "cluster snapshot was not taken")
+
+	after := SnapshotClusterState()
+	diff := k8s.DiffClusterSnapshots(before, after)
+
+	shared.LogLevel("info", "Cluster state changes: %s", diff)
+	AddReportEntry("cluster state diff", diff.String())
+}
@@ -60,6 +60,7 @@ func TestConformance(version string) {
 	testResultTar, err := retrieveResultsTar()
 	Expect(err).NotTo(HaveOccurred())
 	shared.LogLevel("info", "%s", "testResultTar: "+testResultTar)
+	AddReportEntry(shared.ReportAttachmentEntry, testResultTar)
 
 	results := getResults(testResultTar)
 	shared.LogLevel("info", "sonobuoy results: %s", results)
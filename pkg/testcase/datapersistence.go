@@ -0,0 +1,277 @@
+package testcase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	persistenceNamespace       = "data-persistence"
+	persistenceWorkload        = "data-persistence.yaml"
+	persistenceVolumesWorkload = "data-persistence-volumes.yaml"
+	persistenceStorageWorkload = "data-persistence-storage.yaml"
+	persistenceCRD             = "persistencechecks.testing.distros.io"
+	persistenceObjects         = 3
+	persistencePayloadSize     = 32 * 1024
+	persistenceVolumeReplicas  = 2
+	persistenceVolumeSizeKiB   = 4096
+)
+
+// PersistenceData is the checksum of every payload written by SeedPersistenceData.
+//
+// objects are keyed by kind/name and volumes by pod name, the StatefulSet pods keep their name and volume.
+type PersistenceData struct {
+	objects map[string]string
+	volumes map[string]string
+	// storage is set when the local-path-provisioner was deployed for the volumes.
+	storage bool
+}
+
+type persistenceObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Data       map[string]string `json:"data"`
+	BinaryData map[string]string `json:"binaryData"`
+	Spec       struct {
+		Payload string `json:"payload"`
+	} `json:"spec"`
+}
+
+// SeedPersistenceData creates ConfigMaps, Secrets and PersistenceCheck CRs with random payloads and,
+// with volumes, writes a random file on the volume of every StatefulSet pod. Clusters without a default
+// storage class, such as rke2, get a local-path-provisioner for the volumes.
+//
+// call it before a disruptive operation and TestDataPersistence afterwards, volumes should be false
+// when the nodes are replaced since local volumes do not survive them.
+func SeedPersistenceData(volumes bool) *PersistenceData {
+	workloadErr := shared.ManageWorkload("apply", persistenceWorkload)
+	Expect(workloadErr).NotTo(HaveOccurred(), "data persistence manifest not deployed")
+
+	waitCmd := "kubectl wait --for condition=established crd/" + persistenceCRD + " --timeout=60s --kubeconfig=" +
+		shared.KubeConfigFile
+	res, waitErr := shared.RunCommandHost(waitCmd)
+	Expect(waitErr).NotTo(HaveOccurred(), "%s not established: %s", persistenceCRD, res)
+
+	data := &PersistenceData{objects: make(map[string]string), volumes: make(map[string]string)}
+	seedPersistenceObjects(data)
+
+	if volumes {
+		seedPersistenceVolumes(data)
+	}
+
+	shared.LogLevel("info", "Data persistence seeded: %d objects, %d volumes", len(data.objects), len(data.volumes))
+
+	return data
+}
+
+// TestDataPersistence validates every object and volume seeded by SeedPersistenceData is intact and removes them.
+func TestDataPersistence(data *PersistenceData) {
+	Expect(data).NotTo(BeNil(), "data persistence was not seeded")
+
+	Eventually(func(g Gomega) {
+		cmd := "kubectl get configmaps,secrets," + persistenceCRD + " -n " + persistenceNamespace +
+			" -l app=data-persistence -o json --kubeconfig=" + shared.KubeConfigFile
+		res, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred(), res)
+
+		var list struct {
+			Items []persistenceObject `json:"items"`
+		}
+		g.Expect(json.Unmarshal([]byte(res), &list)).To(Succeed())
+
+		found := make(map[string]string, len(list.Items))
+		for _, obj := range list.Items {
+			found[obj.Kind+"/"+obj.Metadata.Name] = obj.checksum()
+		}
+
+		for key, checksum := range data.objects {
+			g.Expect(found).To(HaveKey(key), "%s not found", key)
+			g.Expect(found[key]).To(Equal(checksum), "%s payload changed", key)
+		}
+	}, "300s", "10s").Should(Succeed(), "objects not intact")
+	shared.LogLevel("info", "%d objects intact", len(data.objects))
+
+	if len(data.volumes) > 0 {
+		waitPersistenceVolumes()
+
+		for pod, checksum := range data.volumes {
+			Eventually(func(g Gomega) {
+				sum, err := volumeChecksum(pod, "sha256sum /data/payload")
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(sum).To(Equal(checksum), "volume of %s changed", pod)
+			}, "120s", "10s").Should(Succeed(), "volume of %s not intact", pod)
+		}
+		shared.LogLevel("info", "%d volumes intact", len(data.volumes))
+	}
+
+	workloads := []string{persistenceWorkload}
+	if len(data.volumes) > 0 {
+		workloads = []string{persistenceVolumesWorkload, persistenceWorkload}
+	}
+	// the provisioner goes last, it removes the volume directories of the deleted claims.
+	if data.storage {
+		workloads = append(workloads, persistenceStorageWorkload)
+	}
+	if workloadErr := shared.ManageWorkload("delete", workloads...); workloadErr != nil {
+		shared.LogLevel("warn", "data persistence manifest not deleted: %v", workloadErr)
+	}
+}
+
+// seedPersistenceObjects applies the ConfigMaps, Secrets and CRs with random payloads
+// and records the checksum of the payloads.
+func seedPersistenceObjects(data *PersistenceData) {
+	items := make([]map[string]any, 0, persistenceObjects*3)
+	for i := range persistenceObjects {
+		for _, kind := range []string{"ConfigMap", "Secret", "PersistenceCheck"} {
+			payload := make([]byte, persistencePayloadSize)
+			_, err := rand.Read(payload)
+			Expect(err).NotTo(HaveOccurred(), "error generating payload")
+
+			name := fmt.Sprintf("%s-%d", strings.ToLower(kind), i)
+			data.objects[kind+"/"+name] = checksum(payload)
+			items = append(items, persistenceManifest(kind, name, base64.StdEncoding.EncodeToString(payload)))
+		}
+	}
+
+	manifest, err := json.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": items})
+	Expect(err).NotTo(HaveOccurred())
+
+	file, err := os.CreateTemp("", "data-persistence-*.json")
+	Expect(err).NotTo(HaveOccurred(), "error creating data persistence manifest")
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(manifest)
+	Expect(err).NotTo(HaveOccurred(), "error writing data persistence manifest")
+	Expect(file.Close()).To(Succeed())
+
+	res, err := shared.RunCommandHost("kubectl apply -f " + file.Name() + " --kubeconfig=" + shared.KubeConfigFile)
+	Expect(err).NotTo(HaveOccurred(), "data persistence objects not created: %s", res)
+}
+
+func persistenceManifest(kind, name, payload string) map[string]any {
+	manifest := map[string]any{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": persistenceNamespace,
+			"labels":    map[string]string{"app": "data-persistence"},
+		},
+	}
+
+	switch kind {
+	case "ConfigMap":
+		manifest["binaryData"] = map[string]string{"payload": payload}
+	case "Secret":
+		manifest["data"] = map[string]string{"payload": payload}
+	default:
+		manifest["apiVersion"] = "testing.distros.io/v1"
+		manifest["spec"] = map[string]string{"payload": payload}
+	}
+
+	return manifest
+}
+
+// seedPersistenceVolumes deploys the StatefulSet and writes a random file on the volume of every pod,
+// deploying the local-path-provisioner first when there is no default storage class.
+func seedPersistenceVolumes(data *PersistenceData) {
+	if defaultStorageClass() == "" {
+		shared.LogLevel("info", "No default storage class, deploying local-path-provisioner for the volumes")
+		workloadErr := shared.ManageWorkload("apply", persistenceStorageWorkload)
+		Expect(workloadErr).NotTo(HaveOccurred(), "data persistence storage manifest not deployed")
+		data.storage = true
+
+		Eventually(func(g Gomega) {
+			cmd := "kubectl rollout status deployment/local-path-provisioner -n data-persistence-storage" +
+				" --timeout=10s --kubeconfig=" + shared.KubeConfigFile
+			_, err := shared.RunCommandHost(cmd)
+			g.Expect(err).NotTo(HaveOccurred())
+		}, "300s", "10s").Should(Succeed(), "local-path-provisioner not ready")
+
+		Expect(defaultStorageClass()).NotTo(BeEmpty(), "no default storage class for the data persistence volumes")
+	}
+
+	workloadErr := shared.ManageWorkload("apply", persistenceVolumesWorkload)
+	Expect(workloadErr).NotTo(HaveOccurred(), "data persistence volumes manifest not deployed")
+	waitPersistenceVolumes()
+
+	write := fmt.Sprintf("head -c %d /dev/urandom > /data/payload && sync && sha256sum /data/payload",
+		persistenceVolumeSizeKiB*1024)
+	for i := range persistenceVolumeReplicas {
+		pod := fmt.Sprintf("data-persistence-%d", i)
+		sum, err := volumeChecksum(pod, write)
+		Expect(err).NotTo(HaveOccurred(), "error writing volume of %s", pod)
+		data.volumes[pod] = sum
+	}
+}
+
+// defaultStorageClass returns the name of the default storage class, empty when there is none.
+func defaultStorageClass() string {
+	cmd := "kubectl get storageclass --kubeconfig=" + shared.KubeConfigFile + " -o jsonpath=" +
+		`'{.items[?(@.metadata.annotations.storageclass\.kubernetes\.io/is-default-class=="true")].metadata.name}'`
+	res, err := shared.RunCommandHost(cmd)
+	Expect(err).NotTo(HaveOccurred(), "error getting storage classes: %s", res)
+
+	return strings.TrimSpace(res)
+}
+
+func waitPersistenceVolumes() {
+	Eventually(func(g Gomega) {
+		cmd := "kubectl rollout status statefulset/data-persistence -n " + persistenceNamespace +
+			" --timeout=10s --kubeconfig=" + shared.KubeConfigFile
+		_, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+	}, "300s", "10s").Should(Succeed(), "data persistence statefulset not ready")
+}
+
+// volumeChecksum runs the command in the pod and returns the checksum printed by sha256sum.
+func volumeChecksum(pod, cmd string) (string, error) {
+	execCmd := fmt.Sprintf("kubectl exec %s -n %s --kubeconfig=%s -- sh -c '%s'",
+		pod, persistenceNamespace, shared.KubeConfigFile, cmd)
+	res, err := shared.RunCommandHost(execCmd)
+	if err != nil {
+		return "", fmt.Errorf("error running %q in %s: %w", cmd, pod, err)
+	}
+
+	fields := strings.Fields(res)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no checksum from %s", pod)
+	}
+
+	return fields[0], nil
+}
+
+// checksum returns the checksum of the decoded payload, or the error decoding it.
+func (o persistenceObject) checksum() string {
+	encoded := o.Spec.Payload
+	switch o.Kind {
+	case "ConfigMap":
+		encoded = o.BinaryData["payload"]
+	case "Secret":
+		encoded = o.Data["payload"]
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err.Error()
+	}
+
+	return checksum(payload)
+}
+
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package testcase
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// peerImage is pulled from upstream on one node and from its peer on the node with upstream blocked.
+	peerImage = "docker.io/library/alpine:3.20"
+
+	// upstreamBlockRule rejects the TLS connections to the docker.io registry and its CDN by SNI.
+	upstreamBlockRule = "OUTPUT -p tcp --dport 443 -m string --algo bm --string %s " +
+		"-m comment --comment distros-test-upstream-block -j REJECT"
+)
+
+var upstreamDomains = []string{"docker.io", "docker.com"}
+
+// TestEmbeddedRegistry enables the embedded registry mirror on the servers with docker.io as a mirrored registry,
+// pulls peerImage on a node, blocks upstream on the first server and verifies the image is pulled from the peer,
+// with the supervisor metrics and logs of the first server.
+// The original registries.yaml and config.yaml.d of the nodes are restored at the end of the test.
+func TestEmbeddedRegistry(cluster *shared.Cluster) {
+	Expect(cluster.NumServers+cluster.NumAgents).To(BeNumerically(">", 1), "embedded registry needs two nodes")
+	product := cluster.Config.Product
+	targetIP := cluster.ServerIPs[0]
+	sourceIP := slices.Concat(cluster.ServerIPs[1:], cluster.AgentIPs)[0]
+
+	registries, err := yaml.Marshal(registriesConfig{
+		Mirrors: map[string]registryMirrorEndpoint{"docker.io": {}},
+	})
+	Expect(err).NotTo(HaveOccurred(), "error encoding registries.yaml")
+
+	var restores []func()
+	defer func() { restoreNodeFiles(cluster, restores) }()
+
+	configDir := "/etc/rancher/" + product
+	for _, ip := range cluster.ServerIPs {
+		restores = append(restores,
+			backupNodeFile(ip, configDir+"/registries.yaml"),
+			backupNodeFile(ip, configDir+"/config.yaml.d/embedded-registry.yaml"))
+		stageFiles(ip, configDir, map[string][]byte{
+			"registries.yaml":                      registries,
+			"config.yaml.d/embedded-registry.yaml": []byte("embedded-registry: true\nsupervisor-metrics: true\n"),
+		})
+	}
+	for _, ip := range cluster.AgentIPs {
+		restores = append(restores, backupNodeFile(ip, configDir+"/registries.yaml"))
+		stageFiles(ip, configDir, map[string][]byte{"registries.yaml": registries})
+	}
+	restartNodes(cluster)
+	shared.LogLevel("info", "Embedded registry enabled with docker.io mirrored")
+
+	since := time.Now().Unix()
+	crictl := containerdCommand(product, "crictl")
+
+	res, err := shared.RunCommandOnNode(fmt.Sprintf("%s pull %s", crictl, peerImage), sourceIP)
+	Expect(err).NotTo(HaveOccurred(), "error pulling %s on %s: %s", peerImage, sourceIP, res)
+	shared.LogLevel("info", "%s pulled from upstream on %s", peerImage, sourceIP)
+
+	blockUpstream(targetIP, true)
+	defer blockUpstream(targetIP, false)
+
+	Eventually(func(g Gomega) {
+		pullCmd := fmt.Sprintf("%[1]s rmi %[2]s > /dev/null 2>&1; %[1]s pull %[2]s", crictl, peerImage)
+		pullRes, pullErr := shared.RunCommandOnNode(pullCmd, targetIP)
+		g.Expect(pullErr).NotTo(HaveOccurred(), "error pulling %s on %s: %s", peerImage, targetIP, pullRes)
+	}, "300s", "15s").Should(Succeed(), "%s not pulled from a peer on %s", peerImage, targetIP)
+	shared.LogLevel("info", "%s pulled on %s with upstream blocked", peerImage, targetIP)
+
+	verifyPeerHits(product, targetIP, since)
+}
+
+// blockUpstream adds or deletes the iptables rules rejecting docker.io on the node,
+// then verifies the registry is unreachable while blocked.
+func blockUpstream(ip string, block bool) {
+	action := "-D"
+	if block {
+		action = "-I"
+	}
+
+	for _, domain := range upstreamDomains {
+		cmd := "sudo iptables " + action + " " + fmt.Sprintf(upstreamBlockRule, domain)
+		res, err := shared.RunCommandOnNode(cmd, ip)
+		Expect(err).NotTo(HaveOccurred(), "error updating iptables on %s: %s", ip, res)
+	}
+
+	if block {
+		_, err := shared.RunCommandOnNode("curl -sS -m 10 -o /dev/null https://registry-1.docker.io/v2/", ip)
+		Expect(err).To(HaveOccurred(), "docker.io still reachable from %s", ip)
+		shared.LogLevel("info", "docker.io blocked on %s", ip)
+	}
+}
+
+// verifyPeerHits verifies the spegel metrics of the supervisor count mirror hits and the spegel logs
+// of the product since the time have a mirror request of peerImage served from a peer.
+func verifyPeerHits(product, ip string, since int64) {
+	port := 6443
+	if product == "rke2" {
+		port = 9345
+	}
+	tlsDir := fmt.Sprintf("/var/lib/rancher/%s/server/tls", product)
+	metricsCmd := fmt.Sprintf("sudo curl -s --cacert %[1]s/server-ca.crt --cert %[1]s/client-admin.crt "+
+		"--key %[1]s/client-admin.key https://127.0.0.1:%[2]d/metrics | grep '^spegel_mirror_requests_total'",
+		tlsDir, port)
+
+	res, err := shared.RunCommandOnNode(metricsCmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "spegel metrics not found in the supervisor metrics of %s", ip)
+
+	var hits float64
+	for _, line := range strings.Split(strings.TrimSpace(res), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.Contains(fields[0], `cache="hit"`) {
+			continue
+		}
+		value, parseErr := strconv.ParseFloat(fields[1], 64)
+		Expect(parseErr).NotTo(HaveOccurred(), "invalid metric %s", line)
+		hits += value
+	}
+	Expect(hits).To(BeNumerically(">", 0), "no embedded registry hits on %s:\n%s", ip, res)
+	shared.LogLevel("info", "%v embedded registry hits on %s", hits, ip)
+
+	// spegel logs every request it serves with its handler, path and status, e.g.
+	// "path=/v2/library/alpine/manifests/3.20 status=200 method=GET ... handler=mirror".
+	repository := strings.Split(strings.TrimPrefix(peerImage, "docker.io/"), ":")[0]
+	logsCmd := fmt.Sprintf("sudo journalctl -u %s* --since @%d --no-pager | grep -F '/v2/%s/' | "+
+		"grep -E 'handler\\W+mirror' | grep -E 'status\\W+200'", product, since, repository)
+	logs, err := shared.RunCommandOnNode(logsCmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "no embedded registry mirror hits for %s in the logs of %s", peerImage, ip)
+
+	lines := strings.Split(strings.TrimSpace(logs), "\n")
+	Expect(lines[0]).NotTo(BeEmpty(), "no embedded registry mirror hits for %s in the logs of %s", peerImage, ip)
+	shared.LogLevel("info", "%d embedded registry mirror hits for %s in the logs of %s", len(lines), peerImage, ip)
+	shared.LogLevel("debug", "Embedded registry logs on %s:\n%s", ip, strings.Join(lines[max(0, len(lines)-20):], "\n"))
+}
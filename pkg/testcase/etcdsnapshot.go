@@ -0,0 +1,246 @@
+package testcase
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	extraMetadataConfigMap = "etcd-snapshot-extra-metadata"
+	snapshotScheduleCron   = "*/2 * * * *"
+	snapshotRetention      = 2
+)
+
+// etcdSnapshot is a row of the etcd-snapshot ls output.
+type etcdSnapshot struct {
+	name     string
+	location string
+}
+
+// TestEtcdSnapshot covers the local etcd snapshot lifecycle on the first server:
+// save, ls, delete, prune, compression, scheduled snapshots with retention and the ETCDSnapshotFile resources,
+// finishing with an in-place restore that rolls back the extra metadata configmap.
+func TestEtcdSnapshot(cluster *shared.Cluster) {
+	Expect(cluster.ServerIPs).NotTo(BeEmpty())
+	Expect(cluster.Config.DataStore).NotTo(Equal("external"), "etcd snapshots require the embedded etcd datastore")
+
+	ip := cluster.ServerIPs[0]
+	productCmd, findErr := shared.FindPath(cluster.Config.Product, ip)
+	Expect(findErr).NotTo(HaveOccurred())
+
+	workloadErr := shared.ManageWorkload("apply", "extra-metadata.yaml")
+	Expect(workloadErr).NotTo(HaveOccurred(), "configmap failed to create")
+
+	restoreSnapshot := saveLocalSnapshot(productCmd, ip, "lifecycle", false)
+	shared.LogLevel("info", "on-demand snapshot saved: %s", restoreSnapshot.name)
+
+	compressed := saveLocalSnapshot(productCmd, ip, "compressed", true)
+	Expect(compressed.name).To(HaveSuffix(".zip"), "compressed snapshot should be a zip file")
+	shared.LogLevel("info", "compressed snapshot saved: %s", compressed.name)
+
+	deleteLocalSnapshot(productCmd, ip, compressed.name)
+	shared.LogLevel("info", "snapshot deleted: %s", compressed.name)
+
+	pruneLocalSnapshots(productCmd, ip)
+	shared.LogLevel("info", "snapshots pruned")
+
+	scheduledSnapshots(cluster, productCmd, ip)
+	shared.LogLevel("info", "scheduled snapshots validated")
+
+	restoreSnapshotInPlace(cluster, productCmd, restoreSnapshot)
+	shared.LogLevel("info", "snapshot %s restored in place", restoreSnapshot.name)
+}
+
+// saveLocalSnapshot takes an on-demand snapshot and validates it is listed, stored on disk
+// and exposed as an ETCDSnapshotFile.
+func saveLocalSnapshot(productCmd, ip, name string, compress bool) etcdSnapshot {
+	saveCmd := fmt.Sprintf("sudo %s etcd-snapshot save --name %s", productCmd, name)
+	if compress {
+		saveCmd += " --snapshot-compress"
+	}
+
+	saveRes, saveErr := shared.RunCommandOnNode(saveCmd, ip)
+	Expect(saveErr).NotTo(HaveOccurred(), "error saving snapshot: %s", saveRes)
+
+	snapshots, listErr := listLocalSnapshots(productCmd, ip)
+	Expect(listErr).NotTo(HaveOccurred())
+
+	var saved *etcdSnapshot
+	for i := range snapshots {
+		if strings.HasPrefix(snapshots[i].name, name+"-") && (saved == nil || snapshots[i].name > saved.name) {
+			saved = &snapshots[i]
+		}
+	}
+	Expect(saved).NotTo(BeNil(), "snapshot %s not found in etcd-snapshot ls", name)
+	Expect(saved.location).To(HavePrefix("file://"), "snapshot %s should be stored locally", saved.name)
+
+	_, statErr := shared.RunCommandOnNode("sudo ls "+strings.TrimPrefix(saved.location, "file://"), ip)
+	Expect(statErr).NotTo(HaveOccurred(), "snapshot file %s not found on disk", saved.location)
+
+	Eventually(func(g Gomega) {
+		files, err := snapshotFileNames()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(files).To(ContainElement(saved.name))
+	}, "120s", "5s").Should(Succeed(), "ETCDSnapshotFile not created for %s", saved.name)
+
+	return *saved
+}
+
+// deleteLocalSnapshot deletes a snapshot and validates it is gone from the ls output and the ETCDSnapshotFile list.
+func deleteLocalSnapshot(productCmd, ip, name string) {
+	deleteRes, deleteErr := shared.RunCommandOnNode(fmt.Sprintf("sudo %s etcd-snapshot delete %s", productCmd, name), ip)
+	Expect(deleteErr).NotTo(HaveOccurred(), "error deleting snapshot: %s", deleteRes)
+
+	snapshots, listErr := listLocalSnapshots(productCmd, ip)
+	Expect(listErr).NotTo(HaveOccurred())
+	for _, snapshot := range snapshots {
+		Expect(snapshot.name).NotTo(Equal(name), "snapshot %s still listed after delete", name)
+	}
+
+	Eventually(func(g Gomega) {
+		files, err := snapshotFileNames()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(files).NotTo(ContainElement(name))
+	}, "120s", "5s").Should(Succeed(), "ETCDSnapshotFile not removed for %s", name)
+}
+
+// pruneLocalSnapshots takes more snapshots than the retention and validates prune keeps only the newest ones.
+func pruneLocalSnapshots(productCmd, ip string) {
+	const prefix = "prune"
+
+	for i := 0; i <= snapshotRetention; i++ {
+		saveLocalSnapshot(productCmd, ip, prefix, false)
+	}
+
+	pruneCmd := fmt.Sprintf("sudo %s etcd-snapshot prune --name %s --snapshot-retention %d",
+		productCmd, prefix, snapshotRetention)
+	pruneRes, pruneErr := shared.RunCommandOnNode(pruneCmd, ip)
+	Expect(pruneErr).NotTo(HaveOccurred(), "error pruning snapshots: %s", pruneRes)
+
+	snapshots, listErr := listLocalSnapshots(productCmd, ip)
+	Expect(listErr).NotTo(HaveOccurred())
+	Expect(snapshotsWithPrefix(snapshots, prefix+"-")).To(HaveLen(snapshotRetention),
+		"prune should keep %d snapshots", snapshotRetention)
+}
+
+// scheduledSnapshots enables the snapshot schedule on the server with a config.yaml.d drop-in and validates snapshots
+// are taken and pruned to the configured retention, then removes the drop-in.
+func scheduledSnapshots(cluster *shared.Cluster, productCmd, ip string) {
+	dropIn := fmt.Sprintf("/etc/rancher/%s/config.yaml.d/etcd-snapshot.yaml", cluster.Config.Product)
+
+	restore := backupNodeFile(ip, dropIn)
+	defer func() {
+		restore()
+		restartServerService(cluster, ip)
+	}()
+
+	stageFiles(ip, path.Dir(dropIn), map[string][]byte{
+		path.Base(dropIn): []byte(fmt.Sprintf("etcd-snapshot-schedule-cron: %q\netcd-snapshot-retention: %d\n",
+			snapshotScheduleCron, snapshotRetention)),
+	})
+	restartServerService(cluster, ip)
+
+	// scheduled snapshots are named etcd-snapshot-<node>-<timestamp>, wait for retention + 1 cron runs.
+	Eventually(func(g Gomega) {
+		snapshots, err := listLocalSnapshots(productCmd, ip)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(snapshotsWithPrefix(snapshots, "etcd-snapshot-")).To(HaveLen(snapshotRetention))
+	}, "600s", "30s").Should(Succeed(), "scheduled snapshots not taken")
+
+	Consistently(func(g Gomega) {
+		snapshots, err := listLocalSnapshots(productCmd, ip)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(len(snapshotsWithPrefix(snapshots, "etcd-snapshot-"))).To(BeNumerically("<=", snapshotRetention))
+	}, "300s", "30s").Should(Succeed(), "scheduled snapshots not pruned to retention %d", snapshotRetention)
+}
+
+// restoreSnapshotInPlace changes the cluster data after the snapshot, restores the snapshot on the existing servers
+// with --cluster-reset-restore-path and validates the data rolled back.
+func restoreSnapshotInPlace(cluster *shared.Cluster, productCmd string, snapshot etcdSnapshot) {
+	deleteCmd := fmt.Sprintf("kubectl delete configmap %s -n kube-system --kubeconfig=%s",
+		extraMetadataConfigMap, shared.KubeConfigFile)
+	_, deleteErr := shared.RunCommandHost(deleteCmd)
+	Expect(deleteErr).NotTo(HaveOccurred(), "error deleting %s configmap", extraMetadataConfigMap)
+
+	killall(cluster)
+	shared.LogLevel("info", "%s-service killed", cluster.Config.Product)
+
+	ms := shared.NewManageService(5, 5)
+	stopServer(cluster, ms)
+
+	restoreCmd := fmt.Sprintf("sudo %s server --cluster-reset --cluster-reset-restore-path=%s",
+		productCmd, strings.TrimPrefix(snapshot.location, "file://"))
+	clusterReset(cluster, restoreCmd)
+
+	deleteDataDirectories(cluster)
+	restartServer(cluster, ms)
+
+	Eventually(func(g Gomega) {
+		getCmd := fmt.Sprintf("kubectl get configmap %s -n kube-system -o jsonpath='{.data}' --kubeconfig=%s",
+			extraMetadataConfigMap, shared.KubeConfigFile)
+		res, err := shared.RunCommandHost(getCmd)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(res).To(ContainSubstring("lorem_ipsum"))
+	}, "300s", "10s").Should(Succeed(), "%s configmap not rolled back by the restore", extraMetadataConfigMap)
+}
+
+func restartServerService(cluster *shared.Cluster, ip string) {
+	ms := shared.NewManageService(5, 5)
+	action := shared.ServiceAction{
+		Service:  cluster.Config.Product,
+		Action:   "restart",
+		NodeType: "server",
+	}
+	_, err := ms.ManageService(ip, []shared.ServiceAction{action})
+	Expect(err).NotTo(HaveOccurred(), "error restarting %s server service on %s", cluster.Config.Product, ip)
+}
+
+// listLocalSnapshots parses the etcd-snapshot ls output of the node.
+func listLocalSnapshots(productCmd, ip string) ([]etcdSnapshot, error) {
+	res, err := shared.RunCommandOnNode(fmt.Sprintf("sudo %s etcd-snapshot ls 2>/dev/null", productCmd), ip)
+	if err != nil {
+		return nil, shared.ReturnLogError("error listing snapshots: %w", err)
+	}
+
+	var snapshots []etcdSnapshot
+	for _, line := range strings.Split(res, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "Name" {
+			continue
+		}
+		if !strings.HasPrefix(fields[1], "file://") && !strings.HasPrefix(fields[1], "s3://") {
+			continue
+		}
+		snapshots = append(snapshots, etcdSnapshot{name: fields[0], location: fields[1]})
+	}
+
+	return snapshots, nil
+}
+
+// snapshotFileNames returns the snapshot names of the ETCDSnapshotFile resources.
+func snapshotFileNames() ([]string, error) {
+	cmd := "kubectl get etcdsnapshotfiles -o jsonpath='{range .items[*]}{.spec.snapshotName}{\"\\n\"}{end}'" +
+		" --kubeconfig=" + shared.KubeConfigFile
+	res, err := shared.RunCommandHost(cmd)
+	if err != nil {
+		return nil, shared.ReturnLogError("error getting etcdsnapshotfiles: %w", err)
+	}
+
+	return shared.CleanSliceStrings(strings.Split(res, "\n")), nil
+}
+
+func snapshotsWithPrefix(snapshots []etcdSnapshot, prefix string) []etcdSnapshot {
+	var filtered []etcdSnapshot
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.name, prefix) {
+			filtered = append(filtered, snapshot)
+		}
+	}
+
+	return filtered
+}
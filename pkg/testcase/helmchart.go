@@ -0,0 +1,344 @@
+package testcase
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testChartName      = "distros-test-chart"
+	testChartNamespace = "helmchart-test"
+	customizedPodKey   = "distros-test/customized"
+)
+
+// bundledChart is a chart shipped by the product, with values annotating its pods.
+type bundledChart struct {
+	name        string
+	podSelector string
+	values      string
+}
+
+var bundledCharts = map[string]bundledChart{
+	"k3s": {
+		name:        "traefik",
+		podSelector: "app.kubernetes.io/name=traefik",
+		values:      "deployment:\n  podAnnotations:\n    " + customizedPodKey + ": \"true\"\n",
+	},
+	"rke2": {
+		name:        "rke2-ingress-nginx",
+		podSelector: "app.kubernetes.io/name=rke2-ingress-nginx",
+		values:      "controller:\n  podAnnotations:\n    " + customizedPodKey + ": \"true\"\n",
+	},
+}
+
+// TestHelmChart validates the helm-controller: a HelmChart from a chart tarball in chartContent
+// is installed, upgraded through a HelmChartConfig and a new chart version, then deleted,
+// a bundled chart is customized with a HelmChartConfig and disabled with the disable server flag.
+func TestHelmChart(cluster *shared.Cluster) {
+	bundled, ok := bundledCharts[cluster.Config.Product]
+	Expect(ok).To(BeTrue(), "unsupported product: %s", cluster.Config.Product)
+
+	testChartLifecycle()
+	testBundledChartConfig(bundled)
+	testDisableBundledChart(cluster, bundled)
+}
+
+func testChartLifecycle() {
+	applyManifest(helmChartManifest(testChartName, chartContent(testChartName, "0.1.0"), "message: installed\n"))
+	verifyTestChartRelease("installed", "0.1.0", 1)
+	shared.LogLevel("info", "HelmChart %s installed from chartContent", testChartName)
+
+	applyManifest(helmChartConfigManifest(testChartName, "message: customized\n"))
+	verifyTestChartRelease("customized", "0.1.0", 2)
+	shared.LogLevel("info", "HelmChart %s values patched by HelmChartConfig", testChartName)
+
+	applyManifest(helmChartManifest(testChartName, chartContent(testChartName, "0.2.0"), "message: installed\n"))
+	verifyTestChartRelease("customized", "0.2.0", 3)
+	shared.LogLevel("info", "HelmChart %s upgraded to 0.2.0", testChartName)
+
+	for _, kind := range []string{"helmchart", "helmchartconfig"} {
+		res, err := shared.RunCommandHost(fmt.Sprintf("kubectl delete %s %s -n kube-system --kubeconfig=%s",
+			kind, testChartName, shared.KubeConfigFile))
+		Expect(err).NotTo(HaveOccurred(), "error deleting %s %s: %s", kind, testChartName, res)
+	}
+
+	// helm-delete uninstalls the release before the HelmChart finalizer is removed.
+	Eventually(func(g Gomega) {
+		configMap, err := kubectlGet("configmap", testChartNamespace, testChartName, "{.metadata.name}")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap).To(BeEmpty())
+
+		revisions, err := testChartRevisions()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(revisions).To(BeEmpty())
+	}, "300s", "10s").Should(Succeed(), "release %s not removed by helm-delete", testChartName)
+	shared.LogLevel("info", "HelmChart %s deleted and its release uninstalled", testChartName)
+
+	res, err := shared.RunCommandHost(fmt.Sprintf("kubectl delete namespace %s --ignore-not-found --kubeconfig=%s",
+		testChartNamespace, shared.KubeConfigFile))
+	Expect(err).NotTo(HaveOccurred(), "error deleting namespace %s: %s", testChartNamespace, res)
+}
+
+// testBundledChartConfig annotates the pods of the bundled chart through a HelmChartConfig, then removes it.
+// An existing HelmChartConfig of the chart gets the values merged into its valuesContent and is restored afterwards.
+func testBundledChartConfig(bundled bundledChart) {
+	existing, err := kubectlGet("helmchartconfig", "kube-system", bundled.name, "{.metadata.name}")
+	Expect(err).NotTo(HaveOccurred())
+
+	if existing == "" {
+		applyManifest(helmChartConfigManifest(bundled.name, bundled.values))
+		defer func() {
+			res, deleteErr := shared.RunCommandHost(fmt.Sprintf(
+				"kubectl delete helmchartconfig %s -n kube-system --kubeconfig=%s", bundled.name, shared.KubeConfigFile))
+			Expect(deleteErr).NotTo(HaveOccurred(), "error deleting HelmChartConfig %s: %s", bundled.name, res)
+		}()
+	} else {
+		original, getErr := kubectlGet("helmchartconfig", "kube-system", bundled.name, "{.spec.valuesContent}")
+		Expect(getErr).NotTo(HaveOccurred())
+
+		patchValuesContent(bundled.name, mergeValuesContent(original, bundled.values))
+		defer patchValuesContent(bundled.name, original)
+		shared.LogLevel("info", "Values merged into the existing HelmChartConfig %s", bundled.name)
+	}
+
+	Eventually(func(g Gomega) {
+		cmd := fmt.Sprintf(`kubectl get pods -n kube-system -l %s -o jsonpath='{range .items[*]}`+
+			`{.metadata.annotations.%s}{" "}{end}' --kubeconfig=%s`,
+			bundled.podSelector, customizedPodKey, shared.KubeConfigFile)
+		res, getErr := shared.RunCommandHost(cmd)
+		g.Expect(getErr).NotTo(HaveOccurred())
+
+		g.Expect(strings.TrimSpace(res)).NotTo(BeEmpty(), "%s pods not customized", bundled.name)
+		g.Expect(strings.Split(strings.TrimSpace(res), " ")).To(HaveEach("true"), "%s pods not customized", bundled.name)
+	}, "300s", "10s").Should(Succeed(), "HelmChartConfig %s not applied", bundled.name)
+	shared.LogLevel("info", "Bundled chart %s customized by HelmChartConfig", bundled.name)
+}
+
+// testDisableBundledChart adds the bundled chart to the disable server flag and verifies it is uninstalled,
+// then removes the flag and verifies the chart is installed again.
+func testDisableBundledChart(cluster *shared.Cluster, bundled bundledChart) {
+	dropIn := fmt.Sprintf("/etc/rancher/%s/config.yaml.d/disable-chart.yaml", cluster.Config.Product)
+
+	var restores []func()
+	restored := false
+	defer func() {
+		if !restored {
+			restoreNodeFiles(cluster, restores)
+		}
+	}()
+
+	for _, ip := range cluster.ServerIPs {
+		restores = append(restores, backupNodeFile(ip, dropIn))
+		stageFiles(ip, path.Dir(dropIn), map[string][]byte{
+			path.Base(dropIn): []byte("disable+:\n  - " + bundled.name + "\n"),
+		})
+		restartNodeService(cluster, server, ip)
+	}
+
+	Eventually(func(g Gomega) {
+		chart, err := kubectlGet("helmchart", "kube-system", bundled.name, "{.metadata.name}")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(chart).To(BeEmpty())
+
+		cmd := fmt.Sprintf("kubectl get pods -n kube-system -l %s -o name --kubeconfig=%s",
+			bundled.podSelector, shared.KubeConfigFile)
+		res, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(res)).To(BeEmpty(), "%s pods still running", bundled.name)
+	}, "600s", "10s").Should(Succeed(), "%s not uninstalled when disabled", bundled.name)
+	shared.LogLevel("info", "Bundled chart %s disabled with the disable server flag", bundled.name)
+
+	restoreNodeFiles(cluster, restores)
+	restored = true
+
+	Eventually(func(g Gomega) {
+		chart, err := kubectlGet("helmchart", "kube-system", bundled.name, "{.metadata.name}")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(chart).To(Equal(bundled.name))
+
+		cmd := fmt.Sprintf("kubectl get pods -n kube-system -l %s -o jsonpath='{.items[*].status.phase}' --kubeconfig=%s",
+			bundled.podSelector, shared.KubeConfigFile)
+		res, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.Fields(res)).NotTo(BeEmpty(), "%s pods not running", bundled.name)
+		g.Expect(strings.Fields(res)).To(HaveEach("Running"), "%s pods not running", bundled.name)
+	}, "600s", "10s").Should(Succeed(), "%s not installed again when enabled", bundled.name)
+	shared.LogLevel("info", "Bundled chart %s installed again without the disable server flag", bundled.name)
+}
+
+// patchValuesContent replaces the valuesContent of the HelmChartConfig in kube-system.
+func patchValuesContent(name, values string) {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"valuesContent": values}})
+	Expect(err).NotTo(HaveOccurred())
+
+	file, err := os.CreateTemp("", "patch-*.json")
+	Expect(err).NotTo(HaveOccurred(), "error creating patch file")
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(patch)
+	Expect(err).NotTo(HaveOccurred(), "error writing patch file")
+	Expect(file.Close()).To(Succeed())
+
+	res, err := shared.RunCommandHost(fmt.Sprintf(
+		"kubectl patch helmchartconfig %s -n kube-system --type=merge --patch-file=%s --kubeconfig=%s",
+		name, file.Name(), shared.KubeConfigFile))
+	Expect(err).NotTo(HaveOccurred(), "error patching HelmChartConfig %s: %s", name, res)
+}
+
+// mergeValuesContent returns the values yaml of base with overlay merged in, the maps are merged recursively
+// and the other values of overlay replace those of base.
+func mergeValuesContent(base, overlay string) string {
+	var baseValues, overlayValues map[string]any
+	Expect(yaml.Unmarshal([]byte(base), &baseValues)).To(Succeed(), "error parsing valuesContent")
+	Expect(yaml.Unmarshal([]byte(overlay), &overlayValues)).To(Succeed(), "error parsing values")
+
+	merged, err := yaml.Marshal(mergeValues(baseValues, overlayValues))
+	Expect(err).NotTo(HaveOccurred(), "error encoding valuesContent")
+
+	return string(merged)
+}
+
+func mergeValues(base, overlay map[string]any) map[string]any {
+	if base == nil {
+		base = make(map[string]any)
+	}
+	for key, value := range overlay {
+		baseMap, baseIsMap := base[key].(map[string]any)
+		overlayMap, overlayIsMap := value.(map[string]any)
+		if baseIsMap && overlayIsMap {
+			base[key] = mergeValues(baseMap, overlayMap)
+			continue
+		}
+		base[key] = value
+	}
+
+	return base
+}
+
+// verifyTestChartRelease waits for the ConfigMap of the test chart to have the message and chart version,
+// and for the release to be at the revision.
+func verifyTestChartRelease(message, version string, revision int) {
+	Eventually(func(g Gomega) {
+		res, err := kubectlGet("configmap", testChartNamespace, testChartName, "{.data.message}/{.metadata.labels.chart}")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(res).To(Equal(message + "/" + testChartName + "-" + version))
+
+		revisions, err := testChartRevisions()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(slices.Max(append(revisions, 0))).To(Equal(revision))
+	}, "300s", "10s").Should(Succeed(), "%s not at revision %d with chart %s", testChartName, revision, version)
+}
+
+// testChartRevisions returns the revisions of the test chart release secrets.
+func testChartRevisions() ([]int, error) {
+	cmd := fmt.Sprintf(`kubectl get secrets -n %s -l owner=helm,name=%s `+
+		`-o jsonpath='{range .items[*]}{.metadata.labels.version}{" "}{end}' --kubeconfig=%s`,
+		testChartNamespace, testChartName, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s release secrets: %w", testChartName, err)
+	}
+
+	var revisions []int
+	for _, field := range strings.Fields(res) {
+		revision, convErr := strconv.Atoi(field)
+		if convErr != nil {
+			return nil, fmt.Errorf("invalid release revision %s: %w", field, convErr)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+// kubectlGet returns the jsonpath of the object, empty when it does not exist.
+func kubectlGet(kind, namespace, name, jsonpath string) (string, error) {
+	cmd := fmt.Sprintf("kubectl get %s %s -n %s --ignore-not-found -o jsonpath='%s' --kubeconfig=%s",
+		kind, name, namespace, jsonpath, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	if err != nil {
+		return "", fmt.Errorf("error getting %s %s/%s: %s: %w", kind, namespace, name, res, err)
+	}
+
+	return strings.TrimSpace(res), nil
+}
+
+func helmChartManifest(name, content, values string) map[string]any {
+	return map[string]any{
+		"apiVersion": "helm.cattle.io/v1",
+		"kind":       "HelmChart",
+		"metadata":   map[string]any{"name": name, "namespace": "kube-system"},
+		"spec": map[string]any{
+			"chartContent":    content,
+			"targetNamespace": testChartNamespace,
+			"createNamespace": true,
+			"valuesContent":   values,
+		},
+	}
+}
+
+func helmChartConfigManifest(name, values string) map[string]any {
+	return map[string]any{
+		"apiVersion": "helm.cattle.io/v1",
+		"kind":       "HelmChartConfig",
+		"metadata":   map[string]any{"name": name, "namespace": "kube-system"},
+		"spec":       map[string]any{"valuesContent": values},
+	}
+}
+
+// chartContent returns the base64 chart tarball of a chart rendering a ConfigMap with the message value.
+func chartContent(name, version string) string {
+	files := map[string]string{
+		"Chart.yaml":  fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\n", name, version),
+		"values.yaml": "message: default\n",
+		"templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n" +
+			"  labels:\n    chart: {{ .Chart.Name }}-{{ .Chart.Version }}\n" +
+			"data:\n  message: {{ .Values.message | quote }}\n",
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range []string{"Chart.yaml", "values.yaml", "templates/configmap.yaml"} {
+		content := []byte(files[file])
+		Expect(tw.WriteHeader(&tar.Header{Name: name + "/" + file, Mode: 0o644, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write(content)
+		Expect(err).NotTo(HaveOccurred(), "error writing %s to the chart tarball", file)
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// applyManifest applies the object with kubectl.
+func applyManifest(manifest map[string]any) {
+	content, err := json.Marshal(manifest)
+	Expect(err).NotTo(HaveOccurred())
+
+	file, err := os.CreateTemp("", "manifest-*.json")
+	Expect(err).NotTo(HaveOccurred(), "error creating manifest file")
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	Expect(err).NotTo(HaveOccurred(), "error writing manifest file")
+	Expect(file.Close()).To(Succeed())
+
+	res, err := shared.RunCommandHost("kubectl apply -f " + file.Name() + " --kubeconfig=" + shared.KubeConfigFile)
+	Expect(err).NotTo(HaveOccurred(), "error applying %s: %s", manifest["kind"], res)
+}
@@ -0,0 +1,97 @@
+package testcase
+
+import (
+	"fmt"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+// autoDeployName is the manifest file, Addon and ConfigMap name.
+const autoDeployName = "distros-test-manifest"
+
+// TestAutoDeployManifests writes a manifest to the server manifests directory of the first server only and validates
+// its Addon and ConfigMap are applied, updated, kept on the restart of the other servers, not updated while a .skip
+// file exists and removed with the file.
+func TestAutoDeployManifests(cluster *shared.Cluster) {
+	ip := cluster.ServerIPs[0]
+	manifestsDir := fmt.Sprintf("/var/lib/rancher/%s/server/manifests", cluster.Config.Product)
+	manifest := autoDeployName + ".yaml"
+
+	stageFiles(ip, manifestsDir, map[string][]byte{manifest: autoDeployManifest("v1")})
+	checksum := verifyAutoDeploy("v1", "")
+	shared.LogLevel("info", "Manifest %s applied from %s", manifest, ip)
+
+	stageFiles(ip, manifestsDir, map[string][]byte{manifest: autoDeployManifest("v2")})
+	verifyAutoDeploy("v2", checksum)
+	shared.LogLevel("info", "Manifest %s update applied", manifest)
+
+	if len(cluster.ServerIPs) > 1 {
+		for _, serverIP := range cluster.ServerIPs[1:] {
+			restartNodeService(cluster, server, serverIP)
+		}
+		Consistently(func(g Gomega) {
+			value, err := autoDeployedValue()
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(value).To(Equal("v2"))
+		}, "60s", "10s").Should(Succeed(), "%s changed by the servers without the manifest", autoDeployName)
+		shared.LogLevel("info", "Manifest %s kept by the servers without it", manifest)
+	}
+
+	// the .skip file is written before the update, the deploy controller would apply it otherwise.
+	stageFiles(ip, manifestsDir, map[string][]byte{manifest + ".skip": nil})
+	stageFiles(ip, manifestsDir, map[string][]byte{manifest: autoDeployManifest("v3")})
+	Consistently(func(g Gomega) {
+		value, err := autoDeployedValue()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(value).To(Equal("v2"))
+	}, "60s", "10s").Should(Succeed(), "%s updated while skipped", autoDeployName)
+	shared.LogLevel("info", "Manifest %s update skipped with %s.skip", manifest, manifest)
+
+	res, err := shared.RunCommandOnNode(fmt.Sprintf("sudo rm -f %s/%s.skip", manifestsDir, manifest), ip)
+	Expect(err).NotTo(HaveOccurred(), "error removing %s.skip: %s", manifest, res)
+	verifyAutoDeploy("v3", "")
+
+	res, err = shared.RunCommandOnNode(fmt.Sprintf("sudo rm -f %s/%s", manifestsDir, manifest), ip)
+	Expect(err).NotTo(HaveOccurred(), "error removing %s: %s", manifest, res)
+	Eventually(func(g Gomega) {
+		addon, addonErr := kubectlGet("addon", "kube-system", autoDeployName, "{.metadata.name}")
+		g.Expect(addonErr).NotTo(HaveOccurred())
+		g.Expect(addon).To(BeEmpty(), "Addon %s not removed", autoDeployName)
+
+		value, valueErr := autoDeployedValue()
+		g.Expect(valueErr).NotTo(HaveOccurred())
+		g.Expect(value).To(BeEmpty(), "ConfigMap %s not removed", autoDeployName)
+	}, "300s", "10s").Should(Succeed(), "%s not removed with its manifest", autoDeployName)
+	shared.LogLevel("info", "Manifest %s removed with its Addon and ConfigMap", manifest)
+}
+
+// verifyAutoDeploy waits for the ConfigMap to have the value and the Addon a checksum other than previous,
+// then returns the Addon checksum.
+func verifyAutoDeploy(value, previous string) string {
+	var checksum string
+	Eventually(func(g Gomega) {
+		var err error
+		checksum, err = kubectlGet("addon", "kube-system", autoDeployName, "{.spec.checksum}")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(checksum).NotTo(BeEmpty(), "Addon %s not found", autoDeployName)
+		g.Expect(checksum).NotTo(Equal(previous), "Addon %s checksum not updated", autoDeployName)
+
+		deployed, err := autoDeployedValue()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(deployed).To(Equal(value))
+	}, "300s", "10s").Should(Succeed(), "%s not applied with %s", autoDeployName, value)
+
+	return checksum
+}
+
+// autoDeployedValue returns the value of the ConfigMap, empty when it does not exist.
+func autoDeployedValue() (string, error) {
+	return kubectlGet("configmap", "default", autoDeployName, "{.data.value}")
+}
+
+func autoDeployManifest(value string) []byte {
+	return []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n  namespace: default\n"+
+		"data:\n  value: %q\n", autoDeployName, value))
+}
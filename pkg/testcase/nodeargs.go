@@ -0,0 +1,122 @@
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+var argComponents = []string{"kube-apiserver", "kubelet", "etcd"}
+
+// TestNodeArgsConsistency validates on every node that the effective config.yaml is in the node-args annotation
+// and the <component>-arg flags of the annotation are in the kube-apiserver, kubelet and etcd command lines,
+// reporting every flag dropped, overridden or mangled.
+func TestNodeArgsConsistency(cluster *shared.Cluster) {
+	product := cluster.Config.Product
+
+	k8sClient, err := k8s.AddClient()
+	Expect(err).NotTo(HaveOccurred(), "error creating k8s client")
+
+	nodes, err := k8sClient.Clientset.CoreV1().Nodes().List(context.Background(), meta.ListOptions{})
+	Expect(err).NotTo(HaveOccurred(), "error listing nodes")
+
+	var discrepancies []shared.ArgDiscrepancy
+	for _, ip := range slices.Concat(cluster.ServerIPs, cluster.AgentIPs) {
+		node := findNodeByIP(nodes.Items, ip)
+		Expect(node).NotTo(BeNil(), "node with ip %s not found", ip)
+
+		config, configErr := shared.EffectiveConfig(product, ip)
+		Expect(configErr).NotTo(HaveOccurred())
+
+		nodeArgs, nodeArgsErr := shared.NodeArgs(product, node.Name)
+		Expect(nodeArgsErr).NotTo(HaveOccurred())
+
+		discrepancies = append(discrepancies, shared.CompareArgs(node.Name, "node-args", config, nodeArgs)...)
+
+		for _, component := range argComponents {
+			expected := nodeArgs.ComponentArgs(component)
+			if len(expected) == 0 {
+				continue
+			}
+
+			actual := componentArgs(product, ip, component)
+			if actual == nil {
+				shared.LogLevel("debug", "%s not running on %s, skipping its args", component, node.Name)
+				continue
+			}
+			discrepancies = append(discrepancies, shared.CompareArgs(node.Name, component, expected, actual)...)
+		}
+		shared.LogLevel("info", "Compared config.yaml, node-args and component args of %s", node.Name)
+	}
+
+	report := make([]string, 0, len(discrepancies))
+	for _, discrepancy := range discrepancies {
+		report = append(report, discrepancy.String())
+	}
+	Expect(discrepancies).To(BeEmpty(), "node args inconsistencies:\n%s", strings.Join(report, "\n"))
+}
+
+// componentArgs returns the args of the component running on the node, nil when it does not run there.
+//
+// rke2 runs the components as processes and their args are read from /proc, k3s embeds them and logs
+// their args on start. etcd reads its config file as well, where the etcd-arg flags may be written.
+func componentArgs(product, ip, component string) shared.Args {
+	var cmd string
+	if product == "rke2" {
+		cmd = fmt.Sprintf("pid=$(pgrep -xo %s) && sudo cat /proc/$pid/cmdline | tr '\\0' '\\n'; true", component)
+	} else {
+		cmd = fmt.Sprintf("sudo journalctl -u %s* --no-pager | grep -o 'Running %s .*' | tail -1", product, component)
+	}
+
+	res, err := shared.RunCommandOnNode(cmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading %s args on %s", component, ip)
+
+	var args shared.Args
+	if res = strings.TrimSpace(res); res != "" {
+		if product == "rke2" {
+			args = shared.ParseArgs(strings.Split(res, "\n"))
+		} else {
+			args = shared.ParseArgs(strings.Fields(strings.TrimSuffix(res, `"`)))
+		}
+	}
+
+	if component == "etcd" {
+		args = mergeEtcdConfig(product, ip, args)
+	}
+
+	return args
+}
+
+// mergeEtcdConfig adds the settings of the etcd config file of the node to the args.
+func mergeEtcdConfig(product, ip string, args shared.Args) shared.Args {
+	cmd := fmt.Sprintf("sudo cat /var/lib/rancher/%s/server/db/etcd/config 2>/dev/null; true", product)
+	res, err := shared.RunCommandOnNode(cmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading etcd config on %s", ip)
+	if strings.TrimSpace(res) == "" {
+		return args
+	}
+
+	var config map[string]any
+	Expect(yaml.Unmarshal([]byte(res), &config)).To(Succeed(), "error parsing etcd config on %s", ip)
+
+	if args == nil {
+		args = make(shared.Args)
+	}
+	for key, value := range config {
+		if _, isMap := value.(map[string]any); isMap {
+			continue
+		}
+		args[key] = append(args[key], fmt.Sprint(value))
+	}
+
+	return args
+}
@@ -0,0 +1,179 @@
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	etcdRoleLabel         = "node-role.kubernetes.io/etcd"
+	controlPlaneRoleLabel = "node-role.kubernetes.io/control-plane"
+)
+
+// roleComponent is a server component, running as a static pod and process on rke2 and embedded in k3s.
+//
+// the embedded k3s apiserver listens on 127.0.0.1:6444, 6443 is the supervisor and agent load balancer port.
+// publicOnly ignores the loopback listeners on rke2, where the agent load balancer listens on 127.0.0.1:6443.
+type roleComponent struct {
+	name         string
+	k3sPort      int
+	rke2Port     int
+	publicOnly   bool
+	controlPlane bool
+}
+
+var roleComponents = []roleComponent{
+	{name: "etcd", k3sPort: 2380, rke2Port: 2380},
+	{name: "kube-apiserver", k3sPort: 6444, rke2Port: 6443, publicOnly: true, controlPlane: true},
+	{name: "kube-controller-manager", k3sPort: 10257, rke2Port: 10257, controlPlane: true},
+	{name: "kube-scheduler", k3sPort: 10259, rke2Port: 10259, controlPlane: true},
+}
+
+// TestNodeRoles validates for every server that exactly the components of its role run,
+// that the node has the labels and taints of its role and that etcd-only nodes reject workloads.
+func TestNodeRoles(cluster *shared.Cluster) {
+	k8sClient, err := k8s.AddClient()
+	Expect(err).NotTo(HaveOccurred(), "error creating k8s client")
+
+	nodes, err := k8sClient.Clientset.CoreV1().Nodes().List(context.Background(), meta.ListOptions{})
+	Expect(err).NotTo(HaveOccurred(), "error listing nodes")
+
+	for _, ip := range cluster.ServerIPs {
+		role, roleErr := shared.NodeRole(cluster.Config.Product, ip)
+		Expect(roleErr).NotTo(HaveOccurred(), "error getting role of %s", ip)
+		shared.LogLevel("info", "Validating %s server %s", role, ip)
+
+		verifyRoleComponents(cluster.Config.Product, ip, role)
+
+		node := findNodeByIP(nodes.Items, ip)
+		Expect(node).NotTo(BeNil(), "node with ip %s not found", ip)
+		verifyRoleLabelsAndTaints(node, role)
+
+		if !role.HasControlPlane() && !role.AcceptsWorkloads() {
+			verifyWorkloadRejected(node.Name)
+		}
+	}
+}
+
+// verifyRoleComponents verifies every component listens on the node only when its role has it,
+// and on rke2 that its static pod manifest and process are there only then.
+func verifyRoleComponents(product, ip string, role shared.NodeType) {
+	for _, component := range roleComponents {
+		expected := role.HasEtcd()
+		if component.controlPlane {
+			expected = role.HasControlPlane()
+		}
+
+		port, publicOnly := component.k3sPort, false
+		if product == "rke2" {
+			port, publicOnly = component.rke2Port, component.publicOnly
+		}
+		Expect(componentListening(ip, port, publicOnly)).To(Equal(expected),
+			"%s listening on %s, expected %v for %s", component.name, ip, expected, role)
+
+		if product != "rke2" {
+			continue
+		}
+
+		manifest := fmt.Sprintf("/var/lib/rancher/rke2/agent/pod-manifests/%s.yaml", component.name)
+		cmd := fmt.Sprintf("sudo test -f %s && echo manifest; pgrep -x %s > /dev/null && echo process; true",
+			manifest, component.name)
+		res, err := shared.RunCommandOnNode(cmd, ip)
+		Expect(err).NotTo(HaveOccurred(), "error checking %s on %s", component.name, ip)
+		Expect(strings.Contains(res, "manifest")).To(Equal(expected),
+			"%s static pod on %s for %s, expected %v", component.name, ip, role, expected)
+		Expect(strings.Contains(res, "process")).To(Equal(expected),
+			"%s process on %s for %s, expected %v", component.name, ip, role, expected)
+	}
+}
+
+// componentListening returns true when a process listens on the port of the node.
+func componentListening(ip string, port int, publicOnly bool) bool {
+	res, err := shared.RunCommandOnNode(fmt.Sprintf("sudo ss -Htln 'sport = :%d'", port), ip)
+	Expect(err).NotTo(HaveOccurred(), "error checking port %d on %s", port, ip)
+
+	for _, line := range strings.Split(strings.TrimSpace(res), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		if publicOnly && (strings.HasPrefix(fields[3], "127.") || strings.HasPrefix(fields[3], "[::1]")) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// verifyRoleLabelsAndTaints verifies the node role labels and the NoExecute etcd and NoSchedule control-plane taints.
+func verifyRoleLabelsAndTaints(node *core.Node, role shared.NodeType) {
+	Expect(node.Labels[etcdRoleLabel] == "true").To(Equal(role.HasEtcd()),
+		"%s label on %s for %s", etcdRoleLabel, node.Name, role)
+	Expect(node.Labels[controlPlaneRoleLabel] == "true").To(Equal(role.HasControlPlane()),
+		"%s label on %s for %s", controlPlaneRoleLabel, node.Name, role)
+
+	var roleTaints []string
+	for _, taint := range node.Spec.Taints {
+		if strings.HasPrefix(taint.Key, "node-role.kubernetes.io/") {
+			roleTaints = append(roleTaints, taint.Key+":"+string(taint.Effect))
+		}
+	}
+
+	var expected []string
+	if role.HasEtcd() && !role.AcceptsWorkloads() {
+		expected = append(expected, etcdRoleLabel+":NoExecute")
+	}
+	if role.HasControlPlane() && !role.AcceptsWorkloads() {
+		expected = append(expected, controlPlaneRoleLabel+":NoSchedule")
+	}
+	Expect(roleTaints).To(ConsistOf(expected), "role taints on %s for %s", node.Name, role)
+}
+
+// verifyWorkloadRejected verifies a pod selecting the node without tolerations is never scheduled on it.
+func verifyWorkloadRejected(nodeName string) {
+	name := "role-check-" + nodeName
+	overrides := fmt.Sprintf(`{"spec":{"nodeSelector":{"kubernetes.io/hostname":"%s"}}}`, nodeName)
+	cmd := fmt.Sprintf("kubectl run %s --image=busybox --restart=Never --overrides='%s' --kubeconfig=%s -- sleep 3600",
+		name, overrides, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	Expect(err).NotTo(HaveOccurred(), "error creating %s: %s", name, res)
+
+	defer func() {
+		deleteCmd := fmt.Sprintf("kubectl delete pod %s --ignore-not-found --kubeconfig=%s", name, shared.KubeConfigFile)
+		if _, deleteErr := shared.RunCommandHost(deleteCmd); deleteErr != nil {
+			shared.LogLevel("warn", "error deleting pod %s: %v", name, deleteErr)
+		}
+	}()
+
+	Consistently(func(g Gomega) {
+		getCmd := fmt.Sprintf("kubectl get pod %s -o jsonpath='{.status.phase}/{.spec.nodeName}' --kubeconfig=%s",
+			name, shared.KubeConfigFile)
+		status, getErr := shared.RunCommandHost(getCmd)
+		g.Expect(getErr).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(status)).To(Equal("Pending/"), "pod scheduled on %s", nodeName)
+	}, "60s", "10s").Should(Succeed(), "%s accepted a workload", nodeName)
+	shared.LogLevel("info", "%s rejected a workload without tolerations", nodeName)
+}
+
+func findNodeByIP(nodes []core.Node, ip string) *core.Node {
+	for i := range nodes {
+		for _, address := range nodes[i].Status.Addresses {
+			if address.Address == ip {
+				return &nodes[i]
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+package testcase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	routingNamespace = "test-service-routing"
+	routingNodePort  = "30097"
+	proxyModeConfig  = "config.yaml.d/kube-proxy-mode.yaml"
+	defaultProxyMode = "iptables"
+)
+
+var proxyModes = []string{"iptables", "ipvs", "nftables"}
+
+// routingPod is a pod of the service routing workload.
+type routingPod struct {
+	name string
+	node string
+	ip   string
+}
+
+// TestProxyModes switches kube-proxy of every node to each supported proxy mode with kube-proxy-arg
+// and validates the service routing with it, then restores the default mode.
+func TestProxyModes(cluster *shared.Cluster) {
+	workloads := []string{"clusterip.yaml", "nodeport.yaml", "dnsutils.yaml", "service-routing.yaml"}
+	if cluster.Config.Product == "k3s" {
+		workloads = append(workloads, "loadbalancer.yaml")
+	}
+	Expect(shared.ManageWorkload("apply", workloads...)).To(Succeed(), "service routing workloads not deployed")
+
+	for _, mode := range proxyModes {
+		if reason := proxyModeUnsupported(cluster, mode); reason != "" {
+			shared.LogLevel("warn", "Skipping proxy mode %s: %s", mode, reason)
+			continue
+		}
+
+		setProxyMode(cluster, mode)
+		testServiceRouting(cluster)
+		shared.LogLevel("info", "Service routing validated with proxy mode %s", mode)
+	}
+
+	setProxyMode(cluster, "")
+
+	Expect(shared.ManageWorkload("delete", workloads...)).To(Succeed(), "service routing workloads not deleted")
+}
+
+// proxyModeUnsupported returns why the nodes cannot run the proxy mode, empty when they can.
+//
+// ipvs needs the ip_vs kernel modules, nftables is enabled by default from v1.31 and needs a 5.13 kernel.
+func proxyModeUnsupported(cluster *shared.Cluster, mode string) string {
+	if mode == "nftables" {
+		nodes, err := shared.GetNodes(false)
+		Expect(err).NotTo(HaveOccurred(), "error getting nodes")
+
+		for _, node := range nodes {
+			kubeVersion, parseErr := version.ParseGeneric(node.Version)
+			Expect(parseErr).NotTo(HaveOccurred(), "error parsing version %s of %s", node.Version, node.Name)
+			if !kubeVersion.AtLeast(version.MustParseGeneric("v1.31")) {
+				return fmt.Sprintf("%s runs %s, v1.31 or later is required", node.Name, node.Version)
+			}
+		}
+	}
+
+	for _, ip := range slices.Concat(cluster.ServerIPs, cluster.AgentIPs) {
+		switch mode {
+		case "ipvs":
+			res, err := shared.RunCommandOnNode("sudo modprobe -a ip_vs ip_vs_rr ip_vs_wrr ip_vs_sh && echo ok; true", ip)
+			Expect(err).NotTo(HaveOccurred(), "error loading ip_vs modules on %s", ip)
+			if strings.TrimSpace(res) != "ok" {
+				return "ip_vs kernel modules not available on " + ip
+			}
+		case "nftables":
+			res, err := shared.RunCommandOnNode("uname -r", ip)
+			Expect(err).NotTo(HaveOccurred(), "error getting kernel version of %s", ip)
+
+			kernel, parseErr := version.ParseGeneric(strings.TrimSpace(res))
+			Expect(parseErr).NotTo(HaveOccurred(), "error parsing kernel version %s of %s", res, ip)
+			if !kernel.AtLeast(version.MustParseGeneric("5.13")) {
+				return fmt.Sprintf("%s runs kernel %s, 5.13 or later is required", ip, kernel)
+			}
+		}
+	}
+
+	return ""
+}
+
+// setProxyMode writes the proxy mode to config.yaml.d of every node, or removes it when mode is empty,
+// restarts the nodes and waits for kube-proxy to report the mode.
+func setProxyMode(cluster *shared.Cluster, mode string) {
+	configDir := "/etc/rancher/" + cluster.Config.Product
+	expected := mode
+	if mode == "" {
+		expected = defaultProxyMode
+	}
+
+	for _, nodeType := range []string{server, agent} {
+		ips := cluster.ServerIPs
+		if nodeType == agent {
+			ips = cluster.AgentIPs
+		}
+
+		for _, ip := range ips {
+			if mode == "" {
+				res, err := shared.RunCommandOnNode(fmt.Sprintf("sudo rm -f %s/%s", configDir, proxyModeConfig), ip)
+				Expect(err).NotTo(HaveOccurred(), "error removing %s on %s: %s", proxyModeConfig, ip, res)
+			} else {
+				stageFiles(ip, configDir, map[string][]byte{
+					proxyModeConfig: []byte("kube-proxy-arg+:\n  - proxy-mode=" + mode + "\n"),
+				})
+			}
+			restartNodeService(cluster, nodeType, ip)
+		}
+	}
+
+	for _, ip := range slices.Concat(cluster.ServerIPs, cluster.AgentIPs) {
+		Eventually(func(g Gomega) {
+			res, err := shared.RunCommandOnNode("curl -s -m 5 http://127.0.0.1:10249/proxyMode", ip)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(strings.TrimSpace(res)).To(Equal(expected))
+		}, "300s", "10s").Should(Succeed(), "kube-proxy on %s not running in %s mode", ip, expected)
+	}
+	shared.LogLevel("info", "kube-proxy running in %s mode on every node", expected)
+}
+
+// testServiceRouting validates the services of the reused workloads and every routing policy of service-routing.yaml.
+func testServiceRouting(cluster *shared.Cluster) {
+	TestServiceClusterIP(false, false)
+	TestServiceNodePort(false, false)
+	if cluster.Config.Product == "k3s" {
+		TestServiceLoadBalancer(false, false)
+	}
+
+	nodes, err := shared.GetNodes(false)
+	Expect(err).NotTo(HaveOccurred(), "error getting nodes")
+
+	pods := routingPods()
+	verifySourceIPPreserved(nodes, pods)
+	verifyInternalTrafficLocal(nodes, pods)
+	verifySessionAffinity()
+	verifyHeadlessService(pods)
+}
+
+// verifySourceIPPreserved verifies the externalTrafficPolicy: Local NodePort sees the client node IP
+// and drops the traffic on the nodes without an endpoint.
+//
+// the traffic is sent from another node, kube-proxy routes the traffic of the node itself to any endpoint.
+func verifySourceIPPreserved(nodes []shared.Node, pods []routingPod) {
+	var backend, idle *shared.Node
+	for i := range nodes {
+		hasPod := slices.ContainsFunc(pods, func(pod routingPod) bool { return pod.node == nodes[i].Name })
+		switch {
+		case hasPod && backend == nil:
+			backend = &nodes[i]
+		case !hasPod && idle == nil:
+			idle = &nodes[i]
+		}
+	}
+	Expect(backend).NotTo(BeNil(), "no node runs a routing-echo pod")
+
+	clientIdx := slices.IndexFunc(nodes, func(node shared.Node) bool { return node.Name != backend.Name })
+	if clientIdx < 0 {
+		shared.LogLevel("warn", "Skipping source IP preservation, a second node is required")
+		return
+	}
+	client := nodes[clientIdx]
+
+	Eventually(func(g Gomega) {
+		res, err := shared.RunCommandOnNode(
+			fmt.Sprintf("curl -s -m 5 http://%s:%s/clientip", backend.InternalIP, routingNodePort), client.ExternalIP)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(res)).To(HavePrefix(client.InternalIP+":"), "source IP not preserved")
+	}, "120s", "10s").Should(Succeed(), "routing-local-svc on %s did not see the IP of %s", backend.Name, client.Name)
+	shared.LogLevel("info", "externalTrafficPolicy: Local preserved the source IP of %s", client.Name)
+
+	if idle == nil {
+		shared.LogLevel("warn", "Skipping externalTrafficPolicy: Local drop, every node runs a routing-echo pod")
+		return
+	}
+
+	Eventually(func(g Gomega) {
+		res, err := shared.RunCommandOnNode(
+			fmt.Sprintf("curl -s -m 5 http://%s:%s/clientip; true", idle.InternalIP, routingNodePort), backend.ExternalIP)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(res)).To(BeEmpty(), "%s routed to a remote endpoint", idle.Name)
+	}, "120s", "10s").Should(Succeed(), "routing-local-svc not dropped on %s without endpoints", idle.Name)
+	shared.LogLevel("info", "externalTrafficPolicy: Local dropped the traffic on %s", idle.Name)
+}
+
+// verifyInternalTrafficLocal verifies the internalTrafficPolicy: Local ClusterIP only answers from the pods
+// of the node sending the traffic and drops it on the nodes without one.
+func verifyInternalTrafficLocal(nodes []shared.Node, pods []routingPod) {
+	clusterIP := routingClusterIP("routing-internal-local-svc")
+
+	for _, node := range nodes {
+		var local []string
+		for _, pod := range pods {
+			if pod.node == node.Name {
+				local = append(local, pod.name)
+			}
+		}
+
+		Eventually(func(g Gomega) {
+			hostnames, err := curlRepeatedly(node.ExternalIP, "http://"+clusterIP+":8080/hostname", 5)
+			g.Expect(err).NotTo(HaveOccurred())
+			if len(local) == 0 {
+				g.Expect(hostnames).To(BeEmpty(), "%s routed to a remote endpoint", node.Name)
+				return
+			}
+			g.Expect(hostnames).To(HaveLen(5), "%s did not reach its local endpoints", node.Name)
+			g.Expect(hostnames).To(HaveEach(BeElementOf(local)), "%s routed to a remote endpoint", node.Name)
+		}, "120s", "10s").Should(Succeed(), "internalTrafficPolicy: Local not applied on %s", node.Name)
+	}
+	shared.LogLevel("info", "internalTrafficPolicy: Local kept the traffic on the nodes")
+}
+
+// verifySessionAffinity verifies the ClientIP session affinity sends every request of a node to the same pod.
+func verifySessionAffinity() {
+	clusterIP := routingClusterIP("routing-affinity-svc")
+	client := shared.FetchNodeExternalIPs()[0]
+
+	Eventually(func(g Gomega) {
+		hostnames, err := curlRepeatedly(client, "http://"+clusterIP+":8080/hostname", 10)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hostnames).To(HaveLen(10))
+		g.Expect(slices.Compact(slices.Sorted(slices.Values(hostnames)))).To(HaveLen(1), "requests spread over pods")
+	}, "120s", "10s").Should(Succeed(), "routing-affinity-svc did not keep the session of %s", client)
+	shared.LogLevel("info", "ClientIP session affinity kept the requests of %s on one pod", client)
+}
+
+// verifyHeadlessService verifies the headless service has no cluster IP and resolves to the pod IPs.
+func verifyHeadlessService(pods []routingPod) {
+	clusterIP, err := kubectlGet("service", routingNamespace, "routing-headless-svc", "{.spec.clusterIP}")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(clusterIP).To(Equal("None"), "routing-headless-svc has a cluster IP")
+
+	Eventually(func(g Gomega) {
+		res, execErr := shared.RunCommandHost(fmt.Sprintf("kubectl exec -n dnsutils dnsutils --kubeconfig=%s -- "+
+			"nslookup routing-headless-svc.%s.svc.cluster.local", shared.KubeConfigFile, routingNamespace))
+		g.Expect(execErr).NotTo(HaveOccurred())
+		for _, pod := range pods {
+			g.Expect(res).To(ContainSubstring(pod.ip), "%s not resolved", pod.name)
+		}
+	}, "120s", "10s").Should(Succeed(), "routing-headless-svc not resolved to its pods")
+	shared.LogLevel("info", "Headless service resolved to the routing-echo pod IPs")
+}
+
+// routingPods waits for the routing-echo pods to run and returns them.
+func routingPods() []routingPod {
+	var pods []routingPod
+	Eventually(func(g Gomega) {
+		cmd := fmt.Sprintf("kubectl get pods -n %s -l k8s-app=routing-echo --field-selector=status.phase=Running "+
+			`-o jsonpath='{range .items[*]}{.metadata.name} {.spec.nodeName} {.status.podIP}{"\n"}{end}' --kubeconfig=%s`,
+			routingNamespace, shared.KubeConfigFile)
+		res, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		pods = nil
+		for _, line := range strings.Split(strings.TrimSpace(res), "\n") {
+			if fields := strings.Fields(line); len(fields) == 3 {
+				pods = append(pods, routingPod{name: fields[0], node: fields[1], ip: fields[2]})
+			}
+		}
+		g.Expect(pods).To(HaveLen(3))
+	}, "300s", "10s").Should(Succeed(), "routing-echo pods not running")
+
+	return pods
+}
+
+func routingClusterIP(svc string) string {
+	clusterIPs, _, err := shared.FetchClusterIPs(routingNamespace, svc)
+	Expect(err).NotTo(HaveOccurred(), "error getting cluster IP of %s", svc)
+
+	fields := strings.Fields(clusterIPs)
+	Expect(fields).NotTo(BeEmpty(), "%s has no cluster IP", svc)
+
+	return fields[0]
+}
+
+// curlRepeatedly requests the url from the node and returns the non-empty responses, failed requests are dropped.
+func curlRepeatedly(ip, url string, times int) ([]string, error) {
+	cmd := fmt.Sprintf("for i in $(seq %d); do curl -s -m 5 %s; echo; done", times, url)
+	res, err := shared.RunCommandOnNode(cmd, ip)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting %s from %s: %w", url, ip, err)
+	}
+
+	return strings.Fields(res), nil
+}
@@ -0,0 +1,326 @@
+package testcase
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	registryMirrorDir       = "/var/tmp/registry-mirror"
+	registryMirrorName      = "registry-mirror"
+	registryMirrorImage     = "docker.io/library/registry:2"
+	registryMirrorPort      = 5000
+	registryMirrorUser      = "mirror"
+	registryMirrorCAFile    = "registry-mirror-ca.crt"
+	registryMirrorNamespace = "registry-mirror"
+
+	// mirroredImage is pushed under mirroredPrefix, it only resolves through the mirror when the rewrite applies.
+	mirroredImage  = "docker.io/library/busybox:1.36"
+	mirroredPrefix = "mirrored"
+)
+
+// registryMirror is a registry:2 container with TLS and htpasswd auth running on a node.
+type registryMirror struct {
+	nodeIP   string
+	host     string
+	password string
+	ca       *certificateAuthority
+}
+
+type registriesConfig struct {
+	Mirrors map[string]registryMirrorEndpoint `yaml:"mirrors"`
+	Configs map[string]registryConfig         `yaml:"configs,omitempty"`
+}
+
+type registryMirrorEndpoint struct {
+	Endpoint []string          `yaml:"endpoint,omitempty"`
+	Rewrite  map[string]string `yaml:"rewrite,omitempty"`
+}
+
+type registryConfig struct {
+	Auth *registryAuth `yaml:"auth,omitempty"`
+	TLS  *registryTLS  `yaml:"tls,omitempty"`
+}
+
+type registryAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type registryTLS struct {
+	CAFile string `yaml:"ca_file"`
+}
+
+// TestRegistryMirror starts a registry:2 container with TLS and auth on the first server and pushes mirroredImage
+// under mirroredPrefix, then configures it as a docker.io mirror with a rewrite on every node
+// and verifies the containerd hosts.toml and that crictl pulls resolve through the mirror.
+// The original registries.yaml of the nodes is restored and the registry removed at the end of the test.
+func TestRegistryMirror(cluster *shared.Cluster) {
+	product := cluster.Config.Product
+	ips := slices.Concat(cluster.ServerIPs, cluster.AgentIPs)
+
+	internalIPs := nodeInternalIPs()
+	registryIP := internalIPs[cluster.ServerIPs[0]]
+	Expect(registryIP).NotTo(BeEmpty(), "internal ip of %s not found", cluster.ServerIPs[0])
+
+	mirror := startRegistryMirror(product, cluster.ServerIPs[0], registryIP)
+	defer mirror.stop(product)
+	mirror.push(product, mirroredImage, mirroredPrefix)
+
+	var restores []func()
+	defer func() { restoreNodeFiles(cluster, restores) }()
+
+	registries := mirror.registriesYAML(product)
+	for _, ip := range ips {
+		restores = append(restores,
+			backupNodeFile(ip, "/etc/rancher/"+product+"/registries.yaml"),
+			backupNodeFile(ip, "/etc/rancher/"+product+"/"+registryMirrorCAFile))
+		stageFiles(ip, "/etc/rancher/"+product, map[string][]byte{
+			"registries.yaml":    registries,
+			registryMirrorCAFile: encodeCertificates(mirror.ca.cert),
+		})
+	}
+	restartNodes(cluster)
+	shared.LogLevel("info", "registries.yaml with mirror %s applied on every node", mirror.host)
+
+	for _, ip := range ips {
+		verifyHostsToml(product, ip, mirror)
+		verifyMirrorPull(product, ip, internalIPs[ip], mirror)
+	}
+}
+
+// startRegistryMirror generates the CA, serving certificate and htpasswd of the registry
+// and runs registry:2 with host networking in the product containerd of the node.
+func startRegistryMirror(product, nodeIP, registryIP string) *registryMirror {
+	secret := make([]byte, 16)
+	_, err := rand.Read(secret)
+	Expect(err).NotTo(HaveOccurred(), "error generating registry password")
+
+	mirror := &registryMirror{
+		nodeIP:   nodeIP,
+		host:     fmt.Sprintf("%s:%d", registryIP, registryMirrorPort),
+		password: hex.EncodeToString(secret),
+		ca:       newCertificateAuthority(fmt.Sprintf("distros-test-registry-ca@%d", time.Now().Unix()), nil),
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred(), "error generating registry key")
+
+	serving := signCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: registryMirrorName},
+		IPAddresses: []net.IP{net.ParseIP(registryIP)},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().AddDate(1, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, key.Public(), mirror.ca)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(mirror.password), bcrypt.DefaultCost)
+	Expect(err).NotTo(HaveOccurred(), "error hashing registry password")
+
+	stageFiles(nodeIP, registryMirrorDir, map[string][]byte{
+		"ca.crt":   encodeCertificates(mirror.ca.cert),
+		"tls.crt":  encodeCertificates(serving, mirror.ca.cert),
+		"tls.key":  encodeKey(key),
+		"htpasswd": []byte(registryMirrorUser + ":" + string(hash) + "\n"),
+	})
+
+	ctr := containerdCommand(product, "ctr") + " -n " + registryMirrorNamespace
+	runCmd := fmt.Sprintf("%[1]s task kill -s SIGKILL %[2]s; %[1]s container rm %[2]s; "+
+		"%[1]s images pull %[3]s > /dev/null && %[1]s run -d --net-host "+
+		"--mount type=bind,src=%[4]s,dst=/certs,options=rbind:ro --log-uri file://%[4]s/registry.log "+
+		"--env REGISTRY_HTTP_ADDR=0.0.0.0:%[5]d --env REGISTRY_HTTP_TLS_CERTIFICATE=/certs/tls.crt "+
+		"--env REGISTRY_HTTP_TLS_KEY=/certs/tls.key --env REGISTRY_AUTH=htpasswd "+
+		"--env REGISTRY_AUTH_HTPASSWD_REALM=%[2]s --env REGISTRY_AUTH_HTPASSWD_PATH=/certs/htpasswd %[3]s %[2]s",
+		ctr, registryMirrorName, registryMirrorImage, registryMirrorDir, registryMirrorPort)
+
+	res, err := shared.RunCommandOnNode(runCmd, nodeIP)
+	Expect(err).NotTo(HaveOccurred(), "error starting %s on %s: %s", registryMirrorImage, nodeIP, res)
+
+	// the registry rejects anonymous requests, the pulls through the mirror prove the auth of registries.yaml works.
+	Eventually(func(g Gomega) {
+		curlCmd := fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --cacert %s/ca.crt https://%s/v2/",
+			registryMirrorDir, mirror.host)
+		code, curlErr := shared.RunCommandOnNode(curlCmd, nodeIP)
+		g.Expect(curlErr).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(code)).To(Equal("401"))
+	}, "120s", "5s").Should(Succeed(), "%s not serving on %s", registryMirrorName, mirror.host)
+	shared.LogLevel("info", "%s serving on %s", registryMirrorName, mirror.host)
+
+	return mirror
+}
+
+// stop kills and removes the registry container and its files.
+func (m *registryMirror) stop(product string) {
+	ctr := containerdCommand(product, "ctr") + " -n " + registryMirrorNamespace
+	cmd := fmt.Sprintf("%[1]s task kill -s SIGKILL %[2]s; %[1]s task rm -f %[2]s; %[1]s container rm %[2]s; "+
+		"sudo rm -rf %[3]s; true", ctr, registryMirrorName, registryMirrorDir)
+
+	if _, err := shared.RunCommandOnNode(cmd, m.nodeIP); err != nil {
+		shared.LogLevel("warn", "error removing %s on %s: %v", registryMirrorName, m.nodeIP, err)
+		return
+	}
+	shared.LogLevel("info", "%s removed from %s", registryMirrorName, m.nodeIP)
+}
+
+// push copies every platform of image to the registry under prefix.
+func (m *registryMirror) push(product, image, prefix string) {
+	ctr := containerdCommand(product, "ctr") + " -n " + registryMirrorNamespace
+	target := m.host + "/" + prefix + "/" + strings.TrimPrefix(image, "docker.io/")
+
+	cmd := fmt.Sprintf("%[1]s images pull --all-platforms %[2]s > /dev/null && %[1]s images tag --force %[2]s %[3]s && "+
+		"%[1]s images push --user %[4]s:%[5]s --tlscacert %[6]s/ca.crt %[3]s",
+		ctr, image, target, registryMirrorUser, m.password, registryMirrorDir)
+
+	res, err := shared.RunCommandOnNode(cmd, m.nodeIP)
+	Expect(err).NotTo(HaveOccurred(), "error pushing %s: %s", target, res)
+	shared.LogLevel("info", "Pushed %s", target)
+}
+
+// registriesYAML returns a registries.yaml mirroring docker.io to the registry and rewriting library images
+// under mirroredPrefix.
+func (m *registryMirror) registriesYAML(product string) []byte {
+	config := registriesConfig{
+		Mirrors: map[string]registryMirrorEndpoint{
+			"docker.io": {
+				Endpoint: []string{"https://" + m.host},
+				Rewrite:  map[string]string{"^library/(.*)": mirroredPrefix + "/library/$1"},
+			},
+		},
+		Configs: map[string]registryConfig{
+			m.host: {
+				Auth: &registryAuth{Username: registryMirrorUser, Password: m.password},
+				TLS:  &registryTLS{CAFile: fmt.Sprintf("/etc/rancher/%s/%s", product, registryMirrorCAFile)},
+			},
+		},
+	}
+
+	registries, err := yaml.Marshal(config)
+	Expect(err).NotTo(HaveOccurred(), "error encoding registries.yaml")
+
+	return registries
+}
+
+// verifyHostsToml verifies the docker.io hosts.toml generated from registries.yaml has the mirror, its CA and the rewrite.
+func verifyHostsToml(product, ip string, mirror *registryMirror) {
+	hostsToml := fmt.Sprintf("/var/lib/rancher/%s/agent/etc/containerd/certs.d/docker.io/hosts.toml", product)
+
+	res, err := shared.RunCommandOnNode("sudo cat "+hostsToml, ip)
+	Expect(err).NotTo(HaveOccurred(), "error reading %s on %s", hostsToml, ip)
+	Expect(res).To(ContainSubstring(`[host."https://%s/v2"]`, mirror.host), "mirror not in hosts.toml on %s", ip)
+	Expect(res).To(ContainSubstring(registryMirrorCAFile), "mirror CA not in hosts.toml on %s", ip)
+	Expect(res).To(ContainSubstring(mirroredPrefix+"/library/$1"), "rewrite not in hosts.toml on %s", ip)
+}
+
+// verifyMirrorPull removes mirroredImage from the node, pulls it with crictl and verifies the registry served
+// the rewritten manifest to the node during the pull, crictl falls back to the upstream registry when the mirror fails.
+func verifyMirrorPull(product, ip, internalIP string, mirror *registryMirror) {
+	crictl := containerdCommand(product, "crictl")
+	registryLog := registryMirrorDir + "/registry.log"
+
+	// the log also holds the push of the image and the pulls of the other nodes, only the lines after the pull count.
+	lines, err := shared.RunCommandOnNode("sudo wc -l < "+registryLog, mirror.nodeIP)
+	Expect(err).NotTo(HaveOccurred(), "error reading %s on %s", registryLog, mirror.nodeIP)
+	offset, err := strconv.Atoi(strings.TrimSpace(lines))
+	Expect(err).NotTo(HaveOccurred(), "unexpected line count of %s: %s", registryLog, lines)
+
+	res, err := shared.RunCommandOnNode(fmt.Sprintf("%[1]s rmi %[2]s > /dev/null 2>&1; %[1]s pull %[2]s",
+		crictl, mirroredImage), ip)
+	Expect(err).NotTo(HaveOccurred(), "error pulling %s on %s: %s", mirroredImage, ip, res)
+
+	manifest := fmt.Sprintf("/v2/%s/library/%s/manifests/", mirroredPrefix,
+		strings.Split(strings.TrimPrefix(mirroredImage, "docker.io/library/"), ":")[0])
+	cmd := fmt.Sprintf("sudo tail -n +%d %s | grep -F '%s' | grep -cF '%s'", offset+1, registryLog, manifest, internalIP)
+
+	hits, err := shared.RunCommandOnNode(cmd, mirror.nodeIP)
+	Expect(err).NotTo(HaveOccurred(), "%s on %s not pulled through %s", mirroredImage, ip, mirror.host)
+	Expect(strings.TrimSpace(hits)).NotTo(Equal("0"), "%s on %s not pulled through %s", mirroredImage, ip, mirror.host)
+	shared.LogLevel("info", "%s pulled through %s on %s, %s manifest requests", mirroredImage, mirror.host, ip,
+		strings.TrimSpace(hits))
+}
+
+// backupNodeFile copies the file on the node aside and returns the function restoring it,
+// which removes the file when it did not exist.
+func backupNodeFile(ip, file string) (restore func()) {
+	backup := file + ".distros-test.bak"
+	res, err := shared.RunCommandOnNode(
+		fmt.Sprintf("if sudo test -f %[1]s; then sudo cp -p %[1]s %[2]s && echo found; fi", file, backup), ip)
+	Expect(err).NotTo(HaveOccurred(), "error backing up %s on %s", file, ip)
+
+	restoreCmd := "sudo rm -f " + file
+	if strings.TrimSpace(res) == "found" {
+		restoreCmd = fmt.Sprintf("sudo mv -f %s %s", backup, file)
+	}
+
+	return func() {
+		if _, restoreErr := shared.RunCommandOnNode(restoreCmd, ip); restoreErr != nil {
+			shared.LogLevel("warn", "error restoring %s on %s: %v", file, ip, restoreErr)
+		}
+	}
+}
+
+// restoreNodeFiles runs the restores and restarts every node to load the restored files.
+func restoreNodeFiles(cluster *shared.Cluster, restores []func()) {
+	if len(restores) == 0 {
+		return
+	}
+	for _, restore := range restores {
+		restore()
+	}
+	restartNodes(cluster)
+	shared.LogLevel("info", "Original node files restored")
+}
+
+// restartNodes restarts the servers and then the agents.
+func restartNodes(cluster *shared.Cluster) {
+	for _, ip := range cluster.ServerIPs {
+		restartNodeService(cluster, server, ip)
+	}
+	for _, ip := range cluster.AgentIPs {
+		restartNodeService(cluster, agent, ip)
+	}
+}
+
+// containerdCommand returns the command running ctr or crictl against the product containerd.
+func containerdCommand(product, tool string) string {
+	Expect(product).To(BeElementOf("k3s", "rke2"), "unsupported product: %s", product)
+
+	if product == "k3s" {
+		return "sudo k3s " + tool
+	}
+	if tool == "crictl" {
+		return "sudo /var/lib/rancher/rke2/bin/crictl --config /var/lib/rancher/rke2/agent/etc/crictl.yaml"
+	}
+
+	return "sudo /var/lib/rancher/rke2/bin/" + tool + " --address /run/k3s/containerd/containerd.sock"
+}
+
+// nodeInternalIPs returns the internal ip of every node keyed by its external ip.
+func nodeInternalIPs() map[string]string {
+	nodes, err := shared.GetNodes(false)
+	Expect(err).NotTo(HaveOccurred(), "error getting nodes")
+
+	ips := make(map[string]string)
+	for _, node := range nodes {
+		ips[node.ExternalIP] = node.InternalIP
+	}
+
+	return ips
+}
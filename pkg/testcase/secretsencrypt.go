@@ -1,6 +1,7 @@
 package testcase
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -108,6 +109,11 @@ func secretsEncryptOps(action, product, serverFlags, primaryNodeIp, cpIP string,
 	if err != nil {
 		return shared.ReturnLogError("error logging secret-encryption file contents...\n%v", err)
 	}
+
+	err = verifyEncryptedAtRest(action, product, primaryNodeIp, cpIP)
+	if err != nil {
+		return shared.ReturnLogError("error verifying secrets are encrypted in etcd after %s\n%v", action, err)
+	}
 	shared.LogLevel("info", "%s secrets-encrypt %s is completed!", product, action)
 
 	return nil
@@ -260,3 +266,93 @@ func logEncryptionFileContents(nodes []shared.Node, serverFlags, action, product
 
 	return nil
 }
+
+// verifyEncryptedAtRest reads the raw Secrets from etcd on etcdIP and verifies they are encrypted with the provider
+// and key of the encryption config on cpIP: the active key after prepare, reencrypt and rotate-keys,
+// any configured key after rotate since the existing Secrets are only rewritten by reencrypt.
+func verifyEncryptedAtRest(action, product, etcdIP, cpIP string) error {
+	provider, keys, err := encryptionKeys(product, cpIP)
+	if err != nil {
+		return err
+	}
+
+	etcd, err := shared.NewEtcd(product, etcdIP)
+	if err != nil {
+		return err
+	}
+
+	values, err := etcd.ValuePrefixes("/registry/secrets/")
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return shared.ReturnLogError("no secrets found in etcd on %s", etcdIP)
+	}
+
+	allowed := keys[:1]
+	if action == "rotate" {
+		allowed = keys
+	}
+
+	for key, value := range values {
+		encrypted := false
+		for _, name := range allowed {
+			if strings.HasPrefix(value, "k8s:enc:"+provider+":v1:"+name+":") {
+				encrypted = true
+				break
+			}
+		}
+		if !encrypted {
+			return shared.ReturnLogError("secret %s is not encrypted with %s key %v, value starts with: %.40q",
+				key, provider, allowed, value)
+		}
+	}
+	shared.LogLevel("info", "%d secrets encrypted in etcd with %s key %v", len(values), provider, allowed)
+
+	return nil
+}
+
+// encryptionKeys returns the provider and the key names of the Secrets encryption config, the active key first.
+func encryptionKeys(product, ip string) (provider string, keys []string, err error) {
+	configFile := fmt.Sprintf("/var/lib/rancher/%s/server/cred/encryption-config.json", product)
+	res, err := shared.RunCommandOnNode("sudo cat "+configFile, ip)
+	if err != nil {
+		return "", nil, shared.ReturnLogError("error reading %s on %s\n%w", configFile, ip, err)
+	}
+
+	type keyList struct {
+		Keys []struct {
+			Name string `json:"name"`
+		} `json:"keys"`
+	}
+	var config struct {
+		Resources []struct {
+			Providers []struct {
+				AESCBC    *keyList `json:"aescbc"`
+				Secretbox *keyList `json:"secretbox"`
+			} `json:"providers"`
+		} `json:"resources"`
+	}
+	if err = json.Unmarshal([]byte(res), &config); err != nil {
+		return "", nil, shared.ReturnLogError("error parsing %s\n%w", configFile, err)
+	}
+	if len(config.Resources) == 0 || len(config.Resources[0].Providers) == 0 {
+		return "", nil, shared.ReturnLogError("no encryption provider in %s", configFile)
+	}
+
+	list := config.Resources[0].Providers[0].AESCBC
+	provider = "aescbc"
+	if list == nil {
+		list = config.Resources[0].Providers[0].Secretbox
+		provider = "secretbox"
+	}
+	if list == nil || len(list.Keys) == 0 {
+		return "", nil, shared.ReturnLogError("no aescbc or secretbox keys in %s", configFile)
+	}
+
+	for _, key := range list.Keys {
+		keys = append(keys, key.Name)
+	}
+
+	return provider, keys, nil
+}
@@ -0,0 +1,127 @@
+package testcase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/pkg/aws"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+// TestTokenRotate rotates the server token on the primary server, updates the token of the other nodes
+// and restarts them, then validates a fresh agent is rejected with the old token and joins with the new one.
+func TestTokenRotate(cluster *shared.Cluster, awsClient *aws.Client, version, channel string) {
+	Expect(cluster.ServerIPs).NotTo(BeEmpty())
+	product := cluster.Config.Product
+	primaryIP := cluster.ServerIPs[0]
+
+	oldToken, err := shared.FetchToken(product, primaryIP)
+	Expect(err).NotTo(HaveOccurred(), "error fetching token")
+	oldToken = strings.TrimSpace(oldToken)
+
+	newSecret := make([]byte, 16)
+	_, err = rand.Read(newSecret)
+	Expect(err).NotTo(HaveOccurred(), "error generating token")
+
+	productCmd, err := shared.FindPath(product, primaryIP)
+	Expect(err).NotTo(HaveOccurred())
+
+	rotateCmd := fmt.Sprintf("sudo %s token rotate --token %s --new-token %s",
+		productCmd, oldToken, hex.EncodeToString(newSecret))
+	res, err := shared.RunCommandOnNode(rotateCmd, primaryIP)
+	Expect(err).NotTo(HaveOccurred(), "error rotating token: %s", res)
+	shared.LogLevel("info", "Token rotated on %s: %s", primaryIP, res)
+
+	restartNodeService(cluster, server, primaryIP)
+
+	newToken, err := shared.FetchToken(product, primaryIP)
+	Expect(err).NotTo(HaveOccurred(), "error fetching rotated token")
+	newToken = strings.TrimSpace(newToken)
+	Expect(newToken).NotTo(Equal(oldToken), "node-token not updated after rotation")
+
+	for _, ip := range cluster.ServerIPs[1:] {
+		updateConfigToken(product, ip, newToken)
+		restartNodeService(cluster, server, ip)
+	}
+	for _, ip := range cluster.AgentIPs {
+		updateConfigToken(product, ip, newToken)
+		restartNodeService(cluster, agent, ip)
+	}
+	shared.LogLevel("info", "Token updated and %s restarted on every node", product)
+
+	Eventually(func(g Gomega) {
+		nodes, nodesErr := shared.GetNodes(false)
+		g.Expect(nodesErr).NotTo(HaveOccurred())
+		g.Expect(nodes).To(HaveLen(cluster.NumServers + cluster.NumAgents))
+		for _, node := range nodes {
+			g.Expect(node.Status).To(Equal("Ready"), "node %s not ready after token rotation", node.Name)
+		}
+	}, "600s", "10s").Should(Succeed(), "nodes not ready after token rotation")
+
+	testAgentJoinTokens(cluster, awsClient, oldToken, newToken, version, channel)
+}
+
+// testAgentJoinTokens creates a fresh agent, validates it does not join with the old token,
+// then joins it with the new token and removes it.
+func testAgentJoinTokens(cluster *shared.Cluster, awsClient *aws.Client, oldToken, newToken, version, channel string) {
+	name := os.Getenv("resource_name") + "-agent-token-rotate"
+	externalIPs, privateIPs, _, err := awsClient.CreateInstances(name)
+	Expect(err).NotTo(HaveOccurred(), "error creating agent instance")
+	externalIP, privateIP := externalIPs[0], privateIPs[0]
+	shared.LogLevel("info", "Created agent %s with ip %s to join with the old and new tokens", name, externalIP)
+
+	defer func() {
+		if deleteErr := awsClient.DeleteInstance(externalIP); deleteErr != nil {
+			shared.LogLevel("warn", "error deleting agent instance %s: %v", externalIP, deleteErr)
+		}
+	}()
+
+	prepSlemicroNodes(externalIPs, cluster.NodeOS, awsClient)
+	Expect(scpToNewNodes(cluster, agent, externalIPs)).To(Succeed(), "error copying join scripts to %s", externalIP)
+
+	joinErr := joinAgent(cluster, awsClient, cluster.ServerIPs[0], oldToken, version, channel, externalIP, privateIP)
+	shared.LogLevel("info", "Agent join with the old token returned: %v", joinErr)
+
+	Consistently(func() bool {
+		return nodeRegistered(privateIP)
+	}, "90s", "10s").Should(BeFalse(), "agent joined with the old token")
+	shared.LogLevel("info", "Agent %s rejected with the old token", externalIP)
+
+	updateConfigToken(cluster.Config.Product, externalIP, newToken)
+	restartNodeService(cluster, agent, externalIP)
+
+	Eventually(func() bool {
+		return nodeRegistered(privateIP)
+	}, "300s", "10s").Should(BeTrue(), "agent did not join with the new token")
+	shared.LogLevel("info", "Agent %s joined with the new token", externalIP)
+
+	Expect(shared.DeleteNode(privateIP)).To(Succeed(), "error deleting node %s", privateIP)
+}
+
+// updateConfigToken replaces the token in the product config.yaml of the node, nodes without a token are unchanged.
+func updateConfigToken(product, ip, token string) {
+	cmd := fmt.Sprintf(`sudo sed -i 's/^token:.*/token: "%s"/' /etc/rancher/%s/config.yaml`, token, product)
+	res, err := shared.RunCommandOnNode(cmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "error updating token on %s: %s", ip, res)
+}
+
+// nodeRegistered returns true when a Ready node has the internal ip.
+func nodeRegistered(ip string) bool {
+	nodes, err := shared.GetNodes(false)
+	if err != nil {
+		return false
+	}
+
+	for _, node := range nodes {
+		if node.InternalIP == ip {
+			return node.Status == "Ready"
+		}
+	}
+
+	return false
+}
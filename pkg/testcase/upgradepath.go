@@ -0,0 +1,273 @@
+package testcase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	upgradePathConfigMap = "upgrade-path-data"
+	upgradePathDataValue = "persisted-across-upgrades"
+)
+
+var (
+	// UpgradePathValidations are the validations run after every hop when none are configured.
+	UpgradePathValidations = []string{"nodes", "pods", "version", "data"}
+
+	minorChannelRegex = regexp.MustCompile(`^v(\d+)\.(\d+)$`)
+	versionRegex      = regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)`)
+)
+
+// UpgradeHopResult is the per hop report entry of an upgrade path.
+type UpgradeHopResult struct {
+	Hop         int
+	From        string
+	To          string
+	Method      string
+	Validations []string
+	Duration    time.Duration
+}
+
+func (r UpgradeHopResult) String() string {
+	return fmt.Sprintf("hop %d: %s -> %s via %s in %s, validated: %s",
+		r.Hop, r.From, r.To, r.Method, r.Duration.Round(time.Second), strings.Join(r.Validations, ","))
+}
+
+type releaseChannels struct {
+	Data []struct {
+		ID     string `json:"id"`
+		Latest string `json:"latest"`
+	} `json:"data"`
+}
+
+// ResolveUpgradePath expands channels and minor ranges of the upgrade path into versions
+// and validates the versions only move forward.
+func ResolveUpgradePath(product string, hops []string) ([]string, error) {
+	if len(hops) == 0 {
+		return nil, shared.ReturnLogError("upgrade path should not be empty")
+	}
+
+	var channels map[string]string
+	var path []string
+	for _, hop := range hops {
+		entries, err := expandMinorRange(hop)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if strings.Contains(entry, product) {
+				path = append(path, entry)
+				continue
+			}
+
+			if channels == nil {
+				channels, err = getReleaseChannels(product)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			latest, ok := channels[entry]
+			if !ok {
+				return nil, shared.ReturnLogError("%s is not a %s version or release channel", entry, product)
+			}
+			shared.LogLevel("info", "Resolved channel %s to %s", entry, latest)
+			path = append(path, latest)
+		}
+	}
+
+	for i := 1; i < len(path); i++ {
+		prev, curr := parseVersion(path[i-1]), parseVersion(path[i])
+		if prev == nil || curr == nil {
+			return nil, shared.ReturnLogError("invalid version in upgrade path: %s", strings.Join(path, ","))
+		}
+		if slices.Compare(prev, curr) > 0 || path[i-1] == path[i] {
+			return nil, shared.ReturnLogError("upgrade path should only move forward: %s -> %s", path[i-1], path[i])
+		}
+		if curr[1]-prev[1] > 1 {
+			shared.LogLevel("warn", "upgrade path skips a minor version: %s -> %s", path[i-1], path[i])
+		}
+	}
+
+	return path, nil
+}
+
+// ParseUpgradeValidations returns the validations to run after every hop.
+func ParseUpgradeValidations(value string) ([]string, error) {
+	if value == "" {
+		return UpgradePathValidations, nil
+	}
+
+	validations := shared.CleanSliceStrings(strings.Split(value, ","))
+	for _, validation := range validations {
+		if !slices.Contains(UpgradePathValidations, validation) {
+			return nil, shared.ReturnLogError("invalid hop validation %s, valid values are: %s",
+				validation, strings.Join(UpgradePathValidations, ","))
+		}
+	}
+
+	return validations, nil
+}
+
+// TestUpgradePathSeedData creates the data checked for persistence after every hop.
+func TestUpgradePathSeedData() {
+	cmd := fmt.Sprintf("kubectl create configmap %s -n default --from-literal=data=%s --kubeconfig=%s",
+		upgradePathConfigMap, upgradePathDataValue, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	Expect(err).NotTo(HaveOccurred(), "error creating %s configmap: %s", upgradePathConfigMap, res)
+}
+
+// TestUpgradePathHop upgrades the cluster to the hop version with the given method
+// and runs the validations, the hop result is added to the spec report.
+func TestUpgradePathHop(
+	cluster *shared.Cluster,
+	k8sClient *k8s.Client,
+	hop int,
+	version, method string,
+	validations []string,
+) {
+	result := UpgradeHopResult{Hop: hop, To: version, Method: method, Validations: validations}
+
+	nodes, err := shared.GetNodes(false)
+	Expect(err).NotTo(HaveOccurred(), "error getting nodes")
+	Expect(nodes).NotTo(BeEmpty())
+	result.From = nodes[0].Version
+
+	shared.LogLevel("info", "Upgrade path hop %d: %s -> %s via %s", hop, result.From, version, method)
+	start := time.Now()
+
+	switch method {
+	case "manual":
+		upgradeErr := TestUpgradeClusterManual(cluster, k8sClient, version)
+		Expect(upgradeErr).NotTo(HaveOccurred(), "error upgrading cluster to %s", version)
+	case "suc":
+		upgradeErr := TestUpgradeClusterSUC(cluster, k8sClient, version)
+		Expect(upgradeErr).NotTo(HaveOccurred(), "error upgrading cluster to %s", version)
+	default:
+		Fail("invalid upgrade method: " + method)
+	}
+
+	validateUpgradeHop(cluster, version, validations)
+
+	result.Duration = time.Since(start)
+	shared.LogLevel("info", "Upgrade path %s", result)
+	AddReportEntry(fmt.Sprintf("upgrade hop %d", hop), result)
+}
+
+func validateUpgradeHop(cluster *shared.Cluster, version string, validations []string) {
+	if slices.Contains(validations, "nodes") || slices.Contains(validations, "version") {
+		var versionAssert assert.NodeAssertFunc
+		if slices.Contains(validations, "version") {
+			versionAssert = assert.NodeAssertVersion(version)
+		}
+		// SUC upgrades are applied asynchronously, allow extra time for every node to report the new version.
+		TestNodeStatus(cluster, assert.NodeAssertReadyStatus(), versionAssert, "1500s")
+	}
+
+	if slices.Contains(validations, "pods") {
+		TestPodStatus(cluster, nil, assert.PodAssertReady())
+	}
+
+	if slices.Contains(validations, "data") {
+		Eventually(func(g Gomega) {
+			cmd := fmt.Sprintf("kubectl get configmap %s -n default -o jsonpath='{.data.data}' --kubeconfig=%s",
+				upgradePathConfigMap, shared.KubeConfigFile)
+			res, err := shared.RunCommandHost(cmd)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(strings.TrimSpace(res)).To(Equal(upgradePathDataValue))
+		}, "120s", "5s").Should(Succeed(), "%s configmap data not persisted after upgrading to %s",
+			upgradePathConfigMap, version)
+	}
+}
+
+// expandMinorRange expands v1.30..v1.32 into v1.30,v1.31,v1.32, any other hop is returned as is.
+func expandMinorRange(hop string) ([]string, error) {
+	from, to, found := strings.Cut(hop, "..")
+	if !found {
+		return []string{hop}, nil
+	}
+
+	fromMatch, toMatch := minorChannelRegex.FindStringSubmatch(from), minorChannelRegex.FindStringSubmatch(to)
+	if fromMatch == nil || toMatch == nil || fromMatch[1] != toMatch[1] {
+		return nil, shared.ReturnLogError("invalid minor range %s, expected format v1.30..v1.32", hop)
+	}
+
+	fromMinor, _ := strconv.Atoi(fromMatch[2])
+	toMinor, _ := strconv.Atoi(toMatch[2])
+	if fromMinor > toMinor {
+		return nil, shared.ReturnLogError("invalid minor range %s, should only move forward", hop)
+	}
+
+	var channels []string
+	for minor := fromMinor; minor <= toMinor; minor++ {
+		channels = append(channels, fmt.Sprintf("v%s.%d", fromMatch[1], minor))
+	}
+
+	return channels, nil
+}
+
+// getReleaseChannels returns the latest release of every channel from the product update server.
+func getReleaseChannels(product string) (map[string]string, error) {
+	url := fmt.Sprintf("https://update.%s.io/v1-release/channels", product)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, shared.ReturnLogError("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, shared.ReturnLogError("error getting release channels from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, shared.ReturnLogError("error getting release channels from %s: %s", url, resp.Status)
+	}
+
+	var rc releaseChannels
+	if err := json.NewDecoder(resp.Body).Decode(&rc); err != nil {
+		return nil, shared.ReturnLogError("error decoding release channels: %w", err)
+	}
+
+	channels := make(map[string]string, len(rc.Data))
+	for _, channel := range rc.Data {
+		if channel.Latest != "" {
+			channels[channel.ID] = channel.Latest
+		}
+	}
+
+	return channels, nil
+}
+
+// parseVersion returns the major, minor and patch numbers of a version.
+func parseVersion(version string) []int {
+	match := versionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return nil
+	}
+
+	parts := make([]int, 0, 3)
+	for _, part := range match[1:] {
+		n, _ := strconv.Atoi(part)
+		parts = append(parts, n)
+	}
+
+	return parts
+}
@@ -0,0 +1,143 @@
+package testcase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/customflag"
+	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/shared"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	rollbackDataConfigMap    = "upgrade-rollback-data"
+	rollbackUpgradeConfigMap = "upgrade-rollback-after-snapshot"
+)
+
+// TestUpgradeRollback takes an etcd snapshot, upgrades the cluster to the install mode version or commit
+// and rolls it back: the previous version is reinstalled on every node, the snapshot is restored on the primary server
+// with --cluster-reset-restore-path and the other servers rejoin with their db directories wiped.
+func TestUpgradeRollback(cluster *shared.Cluster, k8sClient *k8s.Client, flags *customflag.FlagConfig) {
+	version := flags.InstallMode.String()
+	Expect(cluster.ServerIPs).NotTo(BeEmpty())
+	Expect(cluster.Config.DataStore).NotTo(Equal("external"), "rollback requires the embedded etcd datastore")
+
+	nodes, err := shared.GetNodes(false)
+	Expect(err).NotTo(HaveOccurred(), "error getting nodes")
+	Expect(nodes).NotTo(BeEmpty())
+	previousVersion := nodes[0].Version
+	shared.LogLevel("info", "Rolling back to %s after upgrading to %s", previousVersion, version)
+
+	createRollbackConfigMap(rollbackDataConfigMap)
+
+	productCmd, findErr := shared.FindPath(cluster.Config.Product, cluster.ServerIPs[0])
+	Expect(findErr).NotTo(HaveOccurred())
+	snapshot := saveLocalSnapshot(productCmd, cluster.ServerIPs[0], "pre-upgrade", false)
+	shared.LogLevel("info", "pre-upgrade snapshot saved: %s", snapshot.name)
+
+	upgradeErr := TestUpgradeClusterManual(cluster, k8sClient, version)
+	Expect(upgradeErr).NotTo(HaveOccurred(), "error upgrading cluster to %s", version)
+	TestNodeStatus(cluster, assert.NodeAssertReadyStatus(), assert.NodeAssertVersionTypeUpgrade(flags))
+
+	createRollbackConfigMap(rollbackUpgradeConfigMap)
+
+	stopAllServers(cluster)
+	shared.LogLevel("info", "%s servers stopped", cluster.Config.Product)
+
+	for _, ip := range cluster.ServerIPs {
+		reinstallVersion(cluster, server, previousVersion, ip)
+	}
+	shared.LogLevel("info", "%s reinstalled on servers", previousVersion)
+
+	productCmd, findErr = shared.FindPath(cluster.Config.Product, cluster.ServerIPs[0])
+	Expect(findErr).NotTo(HaveOccurred())
+	restoreCmd := fmt.Sprintf("sudo %s server --cluster-reset --cluster-reset-restore-path=%s",
+		productCmd, strings.TrimPrefix(snapshot.location, "file://"))
+	clusterReset(cluster, restoreCmd)
+	shared.LogLevel("info", "snapshot %s restored on %s", snapshot.name, cluster.ServerIPs[0])
+
+	deleteDataDirectories(cluster)
+	restartServer(cluster, shared.NewManageService(5, 5))
+	shared.LogLevel("info", "%s servers restarted", cluster.Config.Product)
+
+	for _, ip := range cluster.AgentIPs {
+		reinstallVersion(cluster, agent, previousVersion, ip)
+		restartNodeService(cluster, agent, ip)
+	}
+	shared.LogLevel("info", "%s reinstalled on agents", previousVersion)
+
+	ok, healthErr := k8sClient.CheckClusterHealth(0)
+	Expect(healthErr).NotTo(HaveOccurred(), "error waiting for cluster to be ready")
+	Expect(ok).To(BeTrue(), "cluster is not healthy after rollback")
+
+	TestNodeStatus(cluster, assert.NodeAssertReadyStatus(),
+		assert.NodeAssertNot(assert.NodeAssertVersionTypeUpgrade(flags)))
+	validateRollbackData()
+}
+
+// stopAllServers kills the product on every server, the primary last, and stops its service.
+func stopAllServers(cluster *shared.Cluster) {
+	killallCmd, findErr := shared.FindPath(cluster.Config.Product+"-killall.sh", cluster.ServerIPs[0])
+	Expect(findErr).NotTo(HaveOccurred())
+
+	ms := shared.NewManageService(5, 5)
+	action := shared.ServiceAction{Service: cluster.Config.Product, Action: stop, NodeType: server}
+	for i := len(cluster.ServerIPs) - 1; i >= 0; i-- {
+		_, err := shared.RunCommandOnNode("sudo "+killallCmd, cluster.ServerIPs[i])
+		Expect(err).NotTo(HaveOccurred(), "error running killall on %s", cluster.ServerIPs[i])
+
+		_, err = ms.ManageService(cluster.ServerIPs[i], []shared.ServiceAction{action})
+		Expect(err).NotTo(HaveOccurred(), "error stopping %s on %s", cluster.Config.Product, cluster.ServerIPs[i])
+	}
+}
+
+// reinstallVersion installs the version binaries on the node without starting the service.
+func reinstallVersion(cluster *shared.Cluster, nodeType, version, ip string) {
+	skipStart := fmt.Sprintf("| sudo INSTALL_%s_SKIP_START=true ", strings.ToUpper(cluster.Config.Product))
+	installCmd := strings.Replace(shared.GetInstallCmd(cluster, version, nodeType), "| sudo ", skipStart, 1)
+	shared.LogLevel("info", "Reinstalling %s %s: %s", nodeType, ip, installCmd)
+
+	res, err := shared.RunCommandOnNode(installCmd, ip)
+	Expect(err).NotTo(HaveOccurred(), "error reinstalling %s on %s: %s", version, ip, res)
+}
+
+func restartNodeService(cluster *shared.Cluster, nodeType, ip string) {
+	ms := shared.NewManageService(5, 5)
+	actions := []shared.ServiceAction{
+		{Service: cluster.Config.Product, Action: restart, NodeType: nodeType},
+		{Service: cluster.Config.Product, Action: status, NodeType: nodeType, ExplicitDelay: 30},
+	}
+	output, err := ms.ManageService(ip, actions)
+	Expect(err).NotTo(HaveOccurred(), "error restarting %s %s on %s", cluster.Config.Product, nodeType, ip)
+	if output != "" {
+		Expect(output).To(ContainSubstring("active "), "%s %s not active on %s", cluster.Config.Product, nodeType, ip)
+	}
+}
+
+func createRollbackConfigMap(name string) {
+	cmd := fmt.Sprintf("kubectl create configmap %s -n default --from-literal=data=%s --kubeconfig=%s",
+		name, name, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	Expect(err).NotTo(HaveOccurred(), "error creating %s configmap: %s", name, res)
+}
+
+// validateRollbackData validates the data from before the snapshot is intact
+// and the data created after the upgrade is gone.
+func validateRollbackData() {
+	Eventually(func(g Gomega) {
+		cmd := fmt.Sprintf("kubectl get configmap %s -n default -o jsonpath='{.data.data}' --kubeconfig=%s",
+			rollbackDataConfigMap, shared.KubeConfigFile)
+		res, err := shared.RunCommandHost(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.TrimSpace(res)).To(Equal(rollbackDataConfigMap))
+	}, "300s", "10s").Should(Succeed(), "%s configmap not intact after rollback", rollbackDataConfigMap)
+
+	cmd := fmt.Sprintf("kubectl get configmap %s -n default --ignore-not-found --kubeconfig=%s",
+		rollbackUpgradeConfigMap, shared.KubeConfigFile)
+	res, err := shared.RunCommandHost(cmd)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(strings.TrimSpace(res)).To(BeEmpty(), "%s configmap should be gone after rollback", rollbackUpgradeConfigMap)
+}
@@ -1,17 +1,28 @@
 package testcase
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 
 	"github.com/rancher/distros-test-framework/pkg/assert"
+	"github.com/rancher/distros-test-framework/pkg/customflag"
 	"github.com/rancher/distros-test-framework/pkg/k8s"
+	"github.com/rancher/distros-test-framework/pkg/suc"
 	"github.com/rancher/distros-test-framework/shared"
 
 	. "github.com/onsi/gomega"
 )
 
+const sucNamespace = "system-upgrade"
+
 // TestUpgradeClusterSUC upgrades cluster using the system-upgrade-controller.
 func TestUpgradeClusterSUC(cluster *shared.Cluster, k8sClient *k8s.Client, version string) error {
 	shared.PrintClusterState()
@@ -44,6 +55,11 @@ func TestUpgradeClusterSUC(cluster *shared.Cluster, k8sClient *k8s.Client, versi
 	}
 
 	newContent := strings.ReplaceAll(string(content), "$UPGRADEVERSION", version)
+	newContent, err = applySucPlanOptions(newContent, cluster.Config.Product, &customflag.ServiceFlag)
+	if err != nil {
+		return fmt.Errorf("failed to apply plan options: %w", err)
+	}
+
 	err = os.WriteFile(newFilePath, []byte(newContent), 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %s", err)
@@ -52,6 +68,14 @@ func TestUpgradeClusterSUC(cluster *shared.Cluster, k8sClient *k8s.Client, versi
 	planApplyErr := shared.ManageWorkload("apply", "plan.yaml")
 	Expect(planApplyErr).NotTo(HaveOccurred(), "failed to upgrade cluster - apply plan.yaml step failed.")
 
+	timeout := customflag.ServiceFlag.SUCPlan.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Minute
+	}
+	shared.LogLevel("info", "Waiting up to %s for the plans to upgrade every node to %s", timeout, version)
+	planErr := k8sClient.WaitForPlansComplete(sucNamespace, version, timeout)
+	Expect(planErr).NotTo(HaveOccurred(), "SUC plans did not upgrade the cluster to %s", version)
+
 	ok, err := k8sClient.CheckClusterHealth(0)
 	Expect(err).NotTo(HaveOccurred(), err, "error checking cluster health")
 	Expect(ok).To(BeTrue(), "cluster health check failed")
@@ -59,30 +83,117 @@ func TestUpgradeClusterSUC(cluster *shared.Cluster, k8sClient *k8s.Client, versi
 	return nil
 }
 
+// applySucPlanOptions applies the concurrency, cordon and drain overrides to the plans of the template.
+func applySucPlanOptions(content, product string, flags *customflag.FlagConfig) (string, error) {
+	opts := flags.SUCPlan
+	if opts.ServerConcurrency == 0 && opts.AgentConcurrency == 0 && opts.Cordon == "" && opts.Drain == "" &&
+		opts.DrainTimeout == "" {
+		return content, nil
+	}
+
+	var plans []map[string]any
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var plan map[string]any
+		if err := decoder.Decode(&plan); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return "", fmt.Errorf("failed to decode plan: %w", err)
+		}
+		if plan == nil {
+			continue
+		}
+
+		if err := setSucPlanOptions(plan, product, flags); err != nil {
+			return "", err
+		}
+		plans = append(plans, plan)
+	}
+
+	var sb strings.Builder
+	encoder := yaml.NewEncoder(&sb)
+	encoder.SetIndent(2)
+	for _, plan := range plans {
+		if err := encoder.Encode(plan); err != nil {
+			return "", fmt.Errorf("failed to encode plan: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode plans: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+func setSucPlanOptions(plan map[string]any, product string, flags *customflag.FlagConfig) error {
+	opts := flags.SUCPlan
+	spec, ok := plan["spec"].(map[string]any)
+	if !ok {
+		return errors.New("plan without spec")
+	}
+
+	metadata, _ := plan["metadata"].(map[string]any)
+	labels, _ := metadata["labels"].(map[string]any)
+	planName, _ := metadata["name"].(string)
+
+	concurrency := opts.AgentConcurrency
+	if labels[product+"-upgrade"] == "server" {
+		concurrency = opts.ServerConcurrency
+	}
+	if concurrency > 0 {
+		spec["concurrency"] = concurrency
+	}
+
+	if opts.Cordon != "" {
+		cordonValue, err := strconv.ParseBool(opts.Cordon)
+		if err != nil {
+			return fmt.Errorf("invalid cordon value %s: %w", opts.Cordon, err)
+		}
+		spec["cordon"] = cordonValue
+	}
+
+	if opts.Drain != "" {
+		drainValue, err := strconv.ParseBool(opts.Drain)
+		if err != nil {
+			return fmt.Errorf("invalid drain value %s: %w", opts.Drain, err)
+		}
+		if !drainValue {
+			delete(spec, "drain")
+		} else if _, found := spec["drain"]; !found {
+			spec["drain"] = map[string]any{"force": true}
+		}
+	}
+
+	if drainSpec, found := spec["drain"].(map[string]any); found && opts.DrainTimeout != "" {
+		drainSpec["timeout"] = opts.DrainTimeout
+	}
+
+	shared.LogLevel("debug", "Plan %s: concurrency %v, cordon %v, drain %v",
+		planName, spec["concurrency"], spec["cordon"], spec["drain"])
+
+	return nil
+}
+
+// applySucYamls applies the vendored manifests of the requested system-upgrade-controller version.
 func applySucYamls() {
-	sucUrl := "https://github.com/rancher/system-upgrade-controller/releases/latest/download/system-upgrade-controller.yaml"
-	sucCRDUrl := "https://github.com/rancher/system-upgrade-controller/releases/latest/download/crd.yaml"
-
-	shared.LogLevel("info", "Applying system-upgrade-controller manifest from url: %s", sucUrl)
-	applyErr := shared.ApplyWorkloadURL(sucUrl)
-	if applyErr != nil {
-		shared.LogLevel(
-			"warn", "error applying system-upgrade-controller manifest from url: %s error: %v", sucUrl, applyErr)
-		shared.LogLevel("debug", "applying system-upgrade-controller manifest from local file")
-		// Fallback to local file if URL fails
-		applyErr = shared.ManageWorkload("apply", "suc.yaml")
-	}
-	Expect(applyErr).NotTo(HaveOccurred(),
-		"system-upgrade-controller manifest did not deploy successfully")
-
-	shared.LogLevel("debug", "Applying SUC CRD manifest from url: %s", sucCRDUrl)
-	applyErr = shared.ApplyWorkloadURL(sucCRDUrl)
-	if applyErr != nil {
-		shared.LogLevel("warn", "error applying SUC CRD manifest from url: %s error: %v", sucCRDUrl, applyErr)
-		shared.LogLevel("debug", "applying SUC CRD manifest from local file")
-		// Fallback to local file if URL fails
-		applyErr = shared.ManageWorkload("apply", "suc_crd.yaml")
-	}
-	Expect(applyErr).NotTo(HaveOccurred(),
-		"suc_crd.yaml apply did not deploy successfully")
+	version := customflag.ServiceFlag.SUCControllerVersion.String()
+	if version == "" {
+		version = suc.DefaultVersion
+	}
+
+	dir, err := os.MkdirTemp("", "suc")
+	Expect(err).NotTo(HaveOccurred(), "error creating temp dir for SUC manifests")
+	defer os.RemoveAll(dir)
+
+	paths, err := suc.WriteManifests(version, dir)
+	Expect(err).NotTo(HaveOccurred(), "error writing SUC %s manifests", version)
+
+	for _, path := range paths {
+		shared.LogLevel("info", "Applying SUC %s manifest: %s", version, filepath.Base(path))
+		res, applyErr := shared.RunCommandHost("kubectl apply -f " + path + " --kubeconfig=" + shared.KubeConfigFile)
+		Expect(applyErr).NotTo(HaveOccurred(), "SUC %s manifest %s did not deploy successfully: %s",
+			version, filepath.Base(path), res)
+	}
 }
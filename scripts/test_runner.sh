@@ -15,11 +15,11 @@ function validate_test_image() {
 function validate_dir(){
   case "$TEST_DIR" in
        upgradecluster|versionbump|mixedoscluster|dualstack|validatecluster|createcluster|selinux|clusterrestore|\
-       certrotate|secretsencrypt|restartservice|deployrancher|clusterreset|rebootinstances|airgap|ipv6only|conformance|nvidia|killalluninstall)
+       certrotate|carotate|tokenrotate|registrymirror|embeddedregistry|helmchart|manifests|proxymode|secretsencrypt|restartservice|deployrancher|clusterreset|etcdsnapshot|rebootinstances|airgap|ipv6only|conformance|nvidia|killalluninstall)
       if [[ "$TEST_DIR" == "upgradecluster" ]];
         then
             case "$TEST_TAG" in
-                upgrademanual|upgradesuc|upgradereplacement)
+                upgrademanual|upgradesuc|upgradereplacement|upgradepath|upgraderollback)
                 ;;
                 *)
                 printf "\n\n%s is not a valid test tag for %s\n\n" "${TEST_TAG}" "${TEST_DIR}"
@@ -55,12 +55,25 @@ function validate_dir(){
 function run() {
 if [ -n "${TEST_DIR}" ]; then
     if [ "${TEST_DIR}" = "upgradecluster" ]; then
+        declare -a PROBE_OPTS
+        [ -n "${AVAILABILITY_PROBE}" ] && PROBE_OPTS+=(-availabilityProbe="${AVAILABILITY_PROBE}")
+        [ -n "${PROBE_MIN_SUCCESS_RATE}" ] && PROBE_OPTS+=(-probeMinSuccessRate "${PROBE_MIN_SUCCESS_RATE}")
+        [ -n "${PROBE_MAX_OUTAGE}" ] && PROBE_OPTS+=(-probeMaxOutage "${PROBE_MAX_OUTAGE}")
         if [ "${TEST_TAG}" = "upgrademanual" ]; then
-            go test -timeout=65m -v -tags=upgrademanual -count=1 ./entrypoint/upgradecluster/... -installVersionOrCommit "${INSTALL_VERSION_OR_COMMIT}" -channel "${CHANNEL}"
+            go test -timeout=65m -v -tags=upgrademanual -count=1 ./entrypoint/upgradecluster/... -installVersionOrCommit "${INSTALL_VERSION_OR_COMMIT}" -channel "${CHANNEL}" "${PROBE_OPTS[@]}" -nodeArgs="${NODE_ARGS:-false}"
         elif [ "${TEST_TAG}" = "upgradesuc" ]; then
-            go test -timeout=65m -v -tags=upgradesuc -count=1 ./entrypoint/upgradecluster/... -sucUpgradeVersion "${SUC_UPGRADE_VERSION}" -channel "${CHANNEL}"
+            go test -timeout=65m -v -tags=upgradesuc -count=1 ./entrypoint/upgradecluster/... -sucUpgradeVersion "${SUC_UPGRADE_VERSION}" -channel "${CHANNEL}" \
+              ${SUC_SERVER_CONCURRENCY:+-sucServerConcurrency "${SUC_SERVER_CONCURRENCY}"} ${SUC_AGENT_CONCURRENCY:+-sucAgentConcurrency "${SUC_AGENT_CONCURRENCY}"} \
+              ${SUC_CORDON:+-sucCordon "${SUC_CORDON}"} ${SUC_DRAIN:+-sucDrain "${SUC_DRAIN}"} ${SUC_DRAIN_TIMEOUT:+-sucDrainTimeout "${SUC_DRAIN_TIMEOUT}"} \
+              ${SUC_UPGRADE_TIMEOUT:+-sucUpgradeTimeout "${SUC_UPGRADE_TIMEOUT}"} ${SUC_CONTROLLER_VERSION:+-sucControllerVersion "${SUC_CONTROLLER_VERSION}"} \
+              "${PROBE_OPTS[@]}"
         elif [ "${TEST_TAG}" = "upgradereplacement" ]; then
-            go test -timeout=120m -v -tags=upgradereplacement -count=1 ./entrypoint/upgradecluster/... -installVersionOrCommit "${INSTALL_VERSION_OR_COMMIT}" -channel "${CHANNEL}"
+            go test -timeout=120m -v -tags=upgradereplacement -count=1 ./entrypoint/upgradecluster/... -installVersionOrCommit "${INSTALL_VERSION_OR_COMMIT}" -channel "${CHANNEL}" "${PROBE_OPTS[@]}"
+        elif [ "${TEST_TAG}" = "upgraderollback" ]; then
+            go test -timeout=90m -v -tags=upgraderollback -count=1 ./entrypoint/upgradecluster/... -installVersionOrCommit "${INSTALL_VERSION_OR_COMMIT}" -channel "${CHANNEL}"
+        elif [ "${TEST_TAG}" = "upgradepath" ]; then
+            go test -timeout=180m -v -tags=upgradepath -count=1 ./entrypoint/upgradecluster/... -upgradePath "${UPGRADE_PATH}" -upgradeMethod "${UPGRADE_METHOD:-manual}" -hopValidations "${HOP_VALIDATIONS}" -channel "${CHANNEL}" --ginkgo.timeout=180m \
+              ${SUC_CONTROLLER_VERSION:+-sucControllerVersion "${SUC_CONTROLLER_VERSION}"}
     fi
     elif [ "${TEST_DIR}" = "versionbump" ]; then
         declare -a OPTS
@@ -100,17 +113,33 @@ if [ -n "${TEST_DIR}" ]; then
     elif [  "${TEST_DIR}" = "createcluster" ]; then
         go test -timeout=60m -v -count=1 ./entrypoint/createcluster/...
     elif [ "${TEST_DIR}" = "validatecluster" ]; then
-        go test -timeout=65m -v -count=1 ./entrypoint/validatecluster/... -destroy "${DESTROY}" -selinux "${SELINUX_TEST}"
+        go test -timeout=65m -v -count=1 ./entrypoint/validatecluster/... -destroy "${DESTROY}" -selinux "${SELINUX_TEST}" -nodeArgs="${NODE_ARGS:-false}"
     elif [ "${TEST_DIR}" = "selinux" ]; then
         go test -timeout=65m -v -count=1 ./entrypoint/selinux/...
     elif [ "${TEST_DIR}" = "certrotate" ]; then
-        go test -timeout=65m -v -count=1 ./entrypoint/certrotate/...
+        go test -timeout=65m -v -count=1 ./entrypoint/certrotate/... -certReport="${CERT_REPORT:-false}"
+    elif [ "${TEST_DIR}" = "carotate" ]; then
+        go test -timeout=90m -v -count=1 ./entrypoint/carotate/... -certReport="${CERT_REPORT:-false}"
+    elif [ "${TEST_DIR}" = "tokenrotate" ]; then
+        go test -timeout=65m -v -count=1 ./entrypoint/tokenrotate/...
+    elif [ "${TEST_DIR}" = "registrymirror" ]; then
+        go test -timeout=60m -v -count=1 ./entrypoint/registrymirror/...
+    elif [ "${TEST_DIR}" = "embeddedregistry" ]; then
+        go test -timeout=60m -v -count=1 ./entrypoint/embeddedregistry/...
+    elif [ "${TEST_DIR}" = "helmchart" ]; then
+        go test -timeout=60m -v -count=1 ./entrypoint/helmchart/...
+    elif [ "${TEST_DIR}" = "manifests" ]; then
+        go test -timeout=45m -v -count=1 ./entrypoint/manifests/...
+    elif [ "${TEST_DIR}" = "proxymode" ]; then
+        go test -timeout=90m -v -count=1 ./entrypoint/proxymode/...
     elif [ "${TEST_DIR}" = "secretsencrypt" ]; then
         go test -timeout=45m -v -count=1 ./entrypoint/secretsencrypt/...
     elif [ "${TEST_DIR}" = "restartservice" ]; then
         go test -timeout=45m -v -count=1 ./entrypoint/restartservice/...
     elif [ "${TEST_DIR}" = "clusterreset" ]; then
         go test -timeout=120m -v -count=1 ./entrypoint/clusterreset/...
+    elif [ "${TEST_DIR}" = "etcdsnapshot" ]; then
+        go test -timeout=120m -v -count=1 ./entrypoint/etcdsnapshot/... -destroy "${DESTROY}"
     elif [ "${TEST_DIR}" = "rebootinstances" ]; then
         go test -timeout=120m -v -count=1 ./entrypoint/rebootinstances/...
     elif [ "${TEST_DIR}" = "airgap" ]; then
@@ -131,6 +160,13 @@ if [ -n "${TEST_DIR}" ]; then
           OPTS=(-timeout=45m -v -count=1 ./entrypoint/clusterrestore/... )
            [ -n "${S3_BUCKET}" ] && OPTS+=(-s3Bucket "${S3_BUCKET}")
            [ -n "${S3_FOLDER}" ] && OPTS+=(-s3Folder "${S3_FOLDER}")
+           [ -n "${S3_ENDPOINT}" ] && OPTS+=(-s3Endpoint "${S3_ENDPOINT}")
+           [ -n "${S3_REGION}" ] && OPTS+=(-s3Region "${S3_REGION}")
+           [ -n "${S3_ACCESS_KEY}" ] && OPTS+=(-s3AccessKey "${S3_ACCESS_KEY}")
+           [ -n "${S3_SECRET_KEY}" ] && OPTS+=(-s3SecretKey "${S3_SECRET_KEY}")
+           [ "${S3_PATH_STYLE}" = "true" ] && OPTS+=(-s3PathStyle)
+           [ "${S3_SKIP_TLS_VERIFY}" = "true" ] && OPTS+=(-s3SkipTLSVerify)
+           [ -n "${S3_ENDPOINT_CA}" ] && OPTS+=(-s3EndpointCA "${S3_ENDPOINT_CA}")
            [ -n "${CHANNEL}"   ] && OPTS+=(-channel "${CHANNEL}")
         go test "${OPTS[@]}"
     elif [ "${TEST_DIR}" = "conformance" ]; then
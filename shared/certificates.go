@@ -0,0 +1,190 @@
+package shared
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Certificate is the first certificate of a file found on a node.
+type Certificate struct {
+	File      string
+	Subject   string
+	Issuer    string
+	Serial    string
+	SANs      []string
+	NotBefore time.Time
+	NotAfter  time.Time
+	KeyUsage  []string
+	IsCA      bool
+	ChainLen  int
+}
+
+// NodeCertificates is the certificate inventory of a node.
+type NodeCertificates struct {
+	IP           string
+	Certificates []Certificate
+}
+
+// CertificateInventory is the certificate inventory of every node.
+type CertificateInventory []NodeCertificates
+
+// ReadNodeCertificates returns the certificates of every *.crt file under server/tls and agent on the node,
+// keyed by file path, temporary certs are skipped.
+func ReadNodeCertificates(product, ip string) (map[string][]*x509.Certificate, error) {
+	dataDir := "/var/lib/rancher/" + product
+	cmd := fmt.Sprintf("sudo find %s/server/tls %s/agent -maxdepth 2 -name '*.crt' -not -path '*/temporary-certs/*' "+
+		`-exec sh -c 'echo "### $1"; cat "$1"' _ {} \; 2>/dev/null; true`, dataDir, dataDir)
+
+	res, err := RunCommandOnNode(cmd, ip)
+	if err != nil {
+		return nil, ReturnLogError("failed to read certificates on %s: %w", ip, err)
+	}
+
+	files := make(map[string][]*x509.Certificate)
+	for _, file := range strings.Split(res, "### ")[1:] {
+		name, content, _ := strings.Cut(file, "\n")
+		if certs := ParseCertificates([]byte(content)); len(certs) > 0 {
+			files[strings.TrimSpace(name)] = certs
+		}
+	}
+
+	return files, nil
+}
+
+// CollectCertificates returns the certificate inventory of the nodes.
+func CollectCertificates(product string, ips []string) (CertificateInventory, error) {
+	inventory := make(CertificateInventory, 0, len(ips))
+	for _, ip := range ips {
+		files, err := ReadNodeCertificates(product, ip)
+		if err != nil {
+			return nil, err
+		}
+
+		node := NodeCertificates{IP: ip}
+		for file, certs := range files {
+			node.Certificates = append(node.Certificates, newCertificate(file, certs))
+		}
+		slices.SortFunc(node.Certificates, func(a, b Certificate) int {
+			return strings.Compare(a.File, b.File)
+		})
+		inventory = append(inventory, node)
+	}
+
+	return inventory, nil
+}
+
+// Node returns the certificates of the node keyed by file path.
+func (inv CertificateInventory) Node(ip string) map[string]Certificate {
+	certs := make(map[string]Certificate)
+	for _, node := range inv {
+		if node.IP != ip {
+			continue
+		}
+		for _, cert := range node.Certificates {
+			certs[cert.File] = cert
+		}
+	}
+
+	return certs
+}
+
+// String returns a table per node with the expiry of every certificate.
+func (inv CertificateInventory) String() string {
+	var sb strings.Builder
+	for _, node := range inv {
+		sb.WriteString(fmt.Sprintf("\nNode %s\n", node.IP))
+
+		w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tSUBJECT\tISSUER\tNOT AFTER\tDAYS LEFT\tUSAGE\tSANS")
+		for _, cert := range node.Certificates {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", cert.File, cert.Subject, cert.Issuer,
+				cert.NotAfter.Format(time.DateOnly), cert.DaysLeft(), strings.Join(cert.KeyUsage, ","),
+				strings.Join(cert.SANs, ","))
+		}
+		_ = w.Flush()
+	}
+
+	return sb.String()
+}
+
+// DaysLeft returns the days until the certificate expires.
+func (c Certificate) DaysLeft() int {
+	return int(math.Floor(time.Until(c.NotAfter).Hours() / 24))
+}
+
+// ParseCertificates returns the certificates of the PEM data, other blocks are skipped.
+func ParseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func newCertificate(file string, certs []*x509.Certificate) Certificate {
+	cert := certs[0]
+	sans := slices.Clone(cert.DNSNames)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	return Certificate{
+		File:      file,
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Serial:    cert.SerialNumber.String(),
+		SANs:      sans,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		KeyUsage:  keyUsages(cert),
+		IsCA:      cert.IsCA,
+		ChainLen:  len(certs),
+	}
+}
+
+func keyUsages(cert *x509.Certificate) []string {
+	names := map[x509.KeyUsage]string{
+		x509.KeyUsageDigitalSignature: "digitalSignature",
+		x509.KeyUsageKeyEncipherment:  "keyEncipherment",
+		x509.KeyUsageCertSign:         "certSign",
+		x509.KeyUsageCRLSign:          "crlSign",
+	}
+	extNames := map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageServerAuth: "serverAuth",
+		x509.ExtKeyUsageClientAuth: "clientAuth",
+	}
+
+	var usages []string
+	for _, usage := range []x509.KeyUsage{
+		x509.KeyUsageDigitalSignature, x509.KeyUsageKeyEncipherment, x509.KeyUsageCertSign, x509.KeyUsageCRLSign,
+	} {
+		if cert.KeyUsage&usage != 0 {
+			usages = append(usages, names[usage])
+		}
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if name, ok := extNames[usage]; ok {
+			usages = append(usages, name)
+		}
+	}
+
+	return usages
+}
@@ -492,7 +492,8 @@ func WriteDataPod(cluster *Cluster, namespace string) (string, error) {
 	return RunCommandHost(cmd)
 }
 
-// GetNodeArgsMap returns list of nodeArgs map.
+// GetNodeArgsMap returns the flags of the node-args annotation of the first node of the node type,
+// with the last value of the repeated flags.
 func GetNodeArgsMap(cluster *Cluster, nodeType string) (map[string]string, error) {
 	res, err := KubectlCommand(
 		cluster,
@@ -500,47 +501,35 @@ func GetNodeArgsMap(cluster *Cluster, nodeType string) (map[string]string, error
 		"get",
 		"nodes "+
 			fmt.Sprintf(
-				`-o jsonpath='{range .items[*]}{.metadata.annotations.%s\.io/node-args}{end}'`,
+				`-o jsonpath='{range .items[*]}{.metadata.annotations.%s\.io/node-args}{"\n"}{end}'`,
 				cluster.Config.Product),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	nodeArgsMapSlice := processNodeArgs(res)
-
-	for _, nodeArgsMap := range nodeArgsMapSlice {
-		if nodeArgsMap["node-type"] == nodeType {
-			return nodeArgsMap, nil
+	for _, annotation := range strings.Split(strings.TrimSpace(res), "\n") {
+		if strings.TrimSpace(annotation) == "" {
+			continue
 		}
-	}
-
-	return nil, nil
-}
-
-func processNodeArgs(nodeArgs string) (nodeArgsMapSlice []map[string]string) {
-	nodeArgsSlice := strings.Split(nodeArgs, "]")
-
-	for _, item := range nodeArgsSlice[:(len(nodeArgsSlice) - 1)] {
-		items := strings.Split(item, `","`)
-		nodeArgsMap := map[string]string{}
 
-		for range items[1:] {
-			nodeArgsMap["node-type"] = strings.Trim(items[0], `["`)
-			regxCompile := regexp.MustCompile(`--|"`)
+		annotationType, args, parseErr := parseNodeArgs(annotation)
+		if parseErr != nil {
+			return nil, ReturnLogError("failed to parse node-args: %w", parseErr)
+		}
+		if annotationType != nodeType {
+			continue
+		}
 
-			for i := 1; i < len(items); i += 2 {
-				if i < (len(items) - 1) {
-					key := regxCompile.ReplaceAllString(items[i], "")
-					value := regxCompile.ReplaceAllString(items[i+1], "")
-					nodeArgsMap[key] = value
-				}
-			}
+		nodeArgsMap := map[string]string{"node-type": annotationType}
+		for flag, values := range args {
+			nodeArgsMap[flag] = values[len(values)-1]
 		}
-		nodeArgsMapSlice = append(nodeArgsMapSlice, nodeArgsMap)
+
+		return nodeArgsMap, nil
 	}
 
-	return nodeArgsMapSlice
+	return nil, nil
 }
 
 // DeleteNode deletes a node from the cluster filtering the name out by the IP.
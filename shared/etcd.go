@@ -0,0 +1,129 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	defaultEtcdctlVersion = "v3.5.21"
+	etcdctlPath           = "/usr/local/bin/etcdctl"
+
+	// rke2EtcdContainer is the etcd container of the rke2 static pod, the container id is looked up on every call
+	// as the pod is recreated when etcd restarts.
+	rke2EtcdContainer = "$(sudo /var/lib/rancher/rke2/bin/crictl --config /var/lib/rancher/rke2/agent/etc/crictl.yaml " +
+		"ps --name '^etcd$' --state running -q | head -1)"
+)
+
+// Etcd runs etcdctl against the embedded etcd of a server node, using the product etcd client certificates.
+type Etcd struct {
+	Product string
+	IP      string
+}
+
+// NewEtcd returns an Etcd for the server node.
+// On rke2 etcdctl runs in the etcd static pod, on k3s, which does not ship etcdctl, it is installed on the node
+// when it is not there yet, with the version of ETCDCTL_VERSION or v3.5.21.
+func NewEtcd(product, ip string) (*Etcd, error) {
+	switch product {
+	case rke2:
+		res, err := RunCommandOnNode(`test -n "`+rke2EtcdContainer+`"`, ip)
+		if err != nil {
+			return nil, ReturnLogError("etcd container not running on %s: %s\n%w", ip, res, err)
+		}
+	case k3s:
+		version := os.Getenv("ETCDCTL_VERSION")
+		if version == "" {
+			version = defaultEtcdctlVersion
+		}
+
+		installCmd := fmt.Sprintf("test -x %[1]s || { arch=$(uname -m | sed 's/x86_64/amd64/;s/aarch64/arm64/'); "+
+			"curl -sfL https://github.com/etcd-io/etcd/releases/download/%[2]s/etcd-%[2]s-linux-${arch}.tar.gz | "+
+			"sudo tar -xz -C /usr/local/bin --strip-components=1 etcd-%[2]s-linux-${arch}/etcdctl; }",
+			etcdctlPath, version)
+
+		res, err := RunCommandOnNode(installCmd, ip)
+		if err != nil {
+			return nil, ReturnLogError("failed to install etcdctl on %s: %s\n%w", ip, res, err)
+		}
+	default:
+		return nil, ReturnLogError("unsupported product: %s", product)
+	}
+
+	return &Etcd{Product: product, IP: ip}, nil
+}
+
+// Run runs etcdctl with the args on the node.
+func (e *Etcd) Run(args string) (string, error) {
+	res, err := RunCommandOnNode(e.command(args), e.IP)
+	if err != nil {
+		return res, fmt.Errorf("etcdctl %s failed on %s: %w", args, e.IP, err)
+	}
+
+	return res, nil
+}
+
+// MemberList returns the etcd members as a table.
+func (e *Etcd) MemberList() (string, error) {
+	return e.Run("member list -w table")
+}
+
+// EndpointHealth returns the health of every etcd member.
+func (e *Etcd) EndpointHealth() (string, error) {
+	return e.Run("endpoint health --cluster -w table")
+}
+
+// EndpointStatus returns the status, db size and leader of every etcd member.
+func (e *Etcd) EndpointStatus() (string, error) {
+	return e.Run("endpoint status --cluster -w table")
+}
+
+// Defrag defragments the etcd member of the node.
+func (e *Etcd) Defrag() (string, error) {
+	return e.Run("defrag")
+}
+
+// AlarmList returns the active etcd alarms, empty when there are none.
+func (e *Etcd) AlarmList() (string, error) {
+	return e.Run("alarm list")
+}
+
+// ValuePrefixes returns the first printable characters of the value of every key under prefix,
+// e.g. the encryption provider and key name of the Secrets under /registry/secrets/.
+func (e *Etcd) ValuePrefixes(prefix string) (map[string]string, error) {
+	etcdctl := e.command("")
+	cmd := fmt.Sprintf("for key in $(%[1]s get %[2]s --prefix --keys-only); do "+
+		"printf '%%s ' \"$key\"; %[1]s get \"$key\" --print-value-only | head -c 128 | tr -dc '[:print:]'; echo; done",
+		etcdctl, prefix)
+
+	res, err := RunCommandOnNode(cmd, e.IP)
+	if err != nil {
+		return nil, ReturnLogError("failed to read keys under %s on %s: %w", prefix, e.IP, err)
+	}
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(res, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// command returns the etcdctl command with the args, the etcd static pod of rke2 mounts the tls dir at the same path.
+func (e *Etcd) command(args string) string {
+	tls := fmt.Sprintf("/var/lib/rancher/%s/server/tls/etcd", e.Product)
+
+	etcdctl := "sudo ETCDCTL_API=3 " + etcdctlPath
+	if e.Product == rke2 {
+		etcdctl = "sudo /var/lib/rancher/rke2/bin/crictl --config /var/lib/rancher/rke2/agent/etc/crictl.yaml exec " +
+			rke2EtcdContainer + " etcdctl"
+	}
+
+	return fmt.Sprintf("%s --endpoints=https://127.0.0.1:2379 --cacert=%s/server-ca.crt "+
+		"--cert=%s/server-client.crt --key=%s/server-client.key %s", etcdctl, tls, tls, tls, args)
+}
@@ -0,0 +1,246 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedArg = "********"
+
+// Args are the values of every flag, repeatable flags have one value per occurrence.
+type Args map[string][]string
+
+// ArgDiscrepancy is a flag of a source that is dropped, overridden or mangled in the next source.
+type ArgDiscrepancy struct {
+	Node     string
+	Source   string
+	Flag     string
+	Kind     string
+	Expected string
+	Actual   []string
+}
+
+func (d ArgDiscrepancy) String() string {
+	return fmt.Sprintf("%s %s: --%s %s, expected %q, got %q", d.Node, d.Source, d.Flag, d.Kind, d.Expected, d.Actual)
+}
+
+// EffectiveConfig returns the product config of the node, config.yaml merged with config.yaml.d/*.yaml
+// in lexical order: a key replaces the previous value and a key ending in + appends to it.
+func EffectiveConfig(product, ip string) (Args, error) {
+	cmd := fmt.Sprintf(`for f in /etc/rancher/%[1]s/config.yaml /etc/rancher/%[1]s/config.yaml.d/*.yaml; do `+
+		`[ -f "$f" ] && echo "### $f" && sudo cat "$f"; done; true`, product)
+
+	res, err := RunCommandOnNode(cmd, ip)
+	if err != nil {
+		return nil, ReturnLogError("failed to read config files on %s: %w", ip, err)
+	}
+
+	files := make(map[string]string)
+	for _, file := range strings.Split(res, "### ")[1:] {
+		name, content, _ := strings.Cut(file, "\n")
+		files[name] = content
+	}
+
+	config, err := MergeConfig(files)
+	if err != nil {
+		return nil, ReturnLogError("failed to merge config files on %s: %w", ip, err)
+	}
+
+	return config, nil
+}
+
+// MergeConfig merges the config files keyed by path, config.yaml first and then the config.yaml.d files
+// in lexical order: a key replaces the previous value and a key ending in + appends to it.
+func MergeConfig(files map[string]string) (Args, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		aDropIn, bDropIn := path.Base(path.Dir(a)) == "config.yaml.d", path.Base(path.Dir(b)) == "config.yaml.d"
+		if aDropIn != bDropIn {
+			if aDropIn {
+				return 1
+			}
+
+			return -1
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	config := make(Args)
+	for _, name := range names {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(files[name]), &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		if doc.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("failed to parse %s: not a mapping", name)
+		}
+
+		// the keys are applied in document order like the product config parser, a key after its + form replaces it.
+		pairs := doc.Content[0].Content
+		for i := 0; i+1 < len(pairs); i += 2 {
+			key := pairs[i].Value
+
+			var value any
+			if err := pairs[i+1].Decode(&value); err != nil {
+				return nil, fmt.Errorf("failed to parse %s of %s: %w", key, name, err)
+			}
+
+			if base, ok := strings.CutSuffix(key, "+"); ok {
+				config[base] = append(config[base], configValues(value)...)
+				continue
+			}
+			config[key] = configValues(value)
+		}
+	}
+
+	return config, nil
+}
+
+// NodeArgs returns the flags of the <product>.io/node-args annotation of the node.
+func NodeArgs(product, nodeName string) (Args, error) {
+	cmd := fmt.Sprintf(`kubectl get node %s -o jsonpath='{.metadata.annotations.%s\.io/node-args}' --kubeconfig=%s`,
+		nodeName, product, KubeConfigFile)
+
+	res, err := RunCommandHost(cmd)
+	if err != nil {
+		return nil, ReturnLogError("failed to get node-args of %s: %w", nodeName, err)
+	}
+
+	_, args, err := parseNodeArgs(res)
+	if err != nil {
+		return nil, ReturnLogError("failed to parse node-args of %s: %w", nodeName, err)
+	}
+
+	return args, nil
+}
+
+// parseNodeArgs returns the server or agent subcommand and the flags of a node-args annotation.
+func parseNodeArgs(annotation string) (nodeType string, args Args, err error) {
+	var nodeArgs []string
+	if err = json.Unmarshal([]byte(strings.TrimSpace(annotation)), &nodeArgs); err != nil {
+		return "", nil, err
+	}
+	if len(nodeArgs) == 0 {
+		return "", make(Args), nil
+	}
+
+	return nodeArgs[0], ParseArgs(nodeArgs[1:]), nil
+}
+
+// ParseArgs returns the flags of the command line args, a flag without a value is true.
+func ParseArgs(args []string) Args {
+	parsed := make(Args)
+	for i := 0; i < len(args); i++ {
+		flag, ok := strings.CutPrefix(strings.TrimSpace(args[i]), "--")
+		if !ok || flag == "" {
+			continue
+		}
+
+		if key, value, found := strings.Cut(flag, "="); found {
+			parsed[key] = append(parsed[key], value)
+			continue
+		}
+
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			parsed[flag] = append(parsed[flag], args[i+1])
+			i++
+			continue
+		}
+		parsed[flag] = append(parsed[flag], "true")
+	}
+
+	return parsed
+}
+
+// ComponentArgs returns the flags passed to a component with <component>-arg, e.g. kubelet-arg.
+func (a Args) ComponentArgs(component string) Args {
+	var args []string
+	for _, value := range a[component+"-arg"] {
+		args = append(args, "--"+value)
+	}
+
+	return ParseArgs(args)
+}
+
+// CompareArgs returns every flag of expected whose values are not all found in actual,
+// redacted values and absent false flags match.
+func CompareArgs(node, source string, expected, actual Args) []ArgDiscrepancy {
+	var discrepancies []ArgDiscrepancy
+
+	flags := make([]string, 0, len(expected))
+	for flag := range expected {
+		flags = append(flags, flag)
+	}
+	slices.Sort(flags)
+
+	for _, flag := range flags {
+		got, found := actual[flag]
+		for _, value := range expected[flag] {
+			kind := argDiscrepancy(value, got, found)
+			if kind == "" {
+				continue
+			}
+			discrepancies = append(discrepancies, ArgDiscrepancy{
+				Node: node, Source: source, Flag: flag, Kind: kind, Expected: value, Actual: got,
+			})
+		}
+	}
+
+	return discrepancies
+}
+
+// argDiscrepancy returns dropped when the flag is missing, mangled when a value only matches after
+// normalizing quotes, case and spaces or contains the expected one, overridden otherwise, or empty when it matches.
+func argDiscrepancy(expected string, actual []string, found bool) string {
+	if !found {
+		if expected == "false" {
+			return ""
+		}
+
+		return "dropped"
+	}
+
+	normalize := func(value string) string {
+		return strings.ToLower(strings.Trim(strings.TrimSpace(value), `"'`))
+	}
+
+	kind := "overridden"
+	for _, value := range actual {
+		switch {
+		case value == expected || value == redactedArg:
+			return ""
+		case normalize(value) == normalize(expected) || strings.Contains(value, expected):
+			kind = "mangled"
+		}
+	}
+
+	return kind
+}
+
+func configValues(value any) []string {
+	switch v := value.(type) {
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,274 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want Args
+	}{
+		{
+			name: "flag with equals",
+			args: []string{"--node-name=server-1"},
+			want: Args{"node-name": {"server-1"}},
+		},
+		{
+			name: "flag with separate value",
+			args: []string{"--data-dir", "/var/lib/rancher"},
+			want: Args{"data-dir": {"/var/lib/rancher"}},
+		},
+		{
+			name: "flag without value is true",
+			args: []string{"--protect-kernel-defaults", "--node-name=server-1"},
+			want: Args{"protect-kernel-defaults": {"true"}, "node-name": {"server-1"}},
+		},
+		{
+			name: "trailing flag without value is true",
+			args: []string{"--node-name=server-1", "--secrets-encryption"},
+			want: Args{"node-name": {"server-1"}, "secrets-encryption": {"true"}},
+		},
+		{
+			name: "repeated flag keeps every value",
+			args: []string{"--kubelet-arg=max-pods=250", "--kubelet-arg", "v=2"},
+			want: Args{"kubelet-arg": {"max-pods=250", "v=2"}},
+		},
+		{
+			name: "value with equals is kept whole",
+			args: []string{"--node-label=role=worker=true"},
+			want: Args{"node-label": {"role=worker=true"}},
+		},
+		{
+			name: "non flags and empty flags are skipped",
+			args: []string{"server", "--", " --tls-san=10.0.0.1 "},
+			want: Args{"tls-san": {"10.0.0.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNodeArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantType   string
+		want       Args
+		wantErr    bool
+	}{
+		{
+			name:       "server",
+			annotation: `["server","--cluster-cidr","10.42.0.0/16,fd00:42::/56","--token","********","--kubelet-arg","v=2"]`,
+			wantType:   "server",
+			want: Args{
+				"cluster-cidr": {"10.42.0.0/16,fd00:42::/56"},
+				"token":        {redactedArg},
+				"kubelet-arg":  {"v=2"},
+			},
+		},
+		{
+			name:       "agent without flags",
+			annotation: "[\"agent\"]\n",
+			wantType:   "agent",
+			want:       Args{},
+		},
+		{
+			name:       "empty annotation",
+			annotation: "[]",
+			want:       Args{},
+		},
+		{
+			name:       "invalid annotation",
+			annotation: `["server","--token"`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeType, got, err := parseNodeArgs(tt.annotation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNodeArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if nodeType != tt.wantType || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNodeArgs() = %s %v, want %s %v", nodeType, got, tt.wantType, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Args
+		actual   Args
+		want     []ArgDiscrepancy
+	}{
+		{
+			name:     "matching values",
+			expected: Args{"node-name": {"server-1"}, "kubelet-arg": {"v=2"}},
+			actual:   Args{"node-name": {"server-1"}, "kubelet-arg": {"max-pods=250", "v=2"}},
+		},
+		{
+			name:     "redacted value matches",
+			expected: Args{"token": {"secret"}},
+			actual:   Args{"token": {redactedArg}},
+		},
+		{
+			name:     "absent false flag matches",
+			expected: Args{"disable-cloud-controller": {"false"}},
+			actual:   Args{},
+		},
+		{
+			name:     "dropped flag",
+			expected: Args{"node-name": {"server-1"}},
+			actual:   Args{},
+			want: []ArgDiscrepancy{
+				{Node: "node", Source: "node-args", Flag: "node-name", Kind: "dropped", Expected: "server-1"},
+			},
+		},
+		{
+			name:     "overridden flag",
+			expected: Args{"cluster-cidr": {"10.42.0.0/16"}},
+			actual:   Args{"cluster-cidr": {"10.52.0.0/16"}},
+			want: []ArgDiscrepancy{
+				{
+					Node: "node", Source: "node-args", Flag: "cluster-cidr", Kind: "overridden",
+					Expected: "10.42.0.0/16", Actual: []string{"10.52.0.0/16"},
+				},
+			},
+		},
+		{
+			name:     "mangled by quotes and case",
+			expected: Args{"node-label": {"Role=Worker"}},
+			actual:   Args{"node-label": {`"role=worker"`}},
+			want: []ArgDiscrepancy{
+				{
+					Node: "node", Source: "node-args", Flag: "node-label", Kind: "mangled",
+					Expected: "Role=Worker", Actual: []string{`"role=worker"`},
+				},
+			},
+		},
+		{
+			name:     "discrepancies sorted by flag",
+			expected: Args{"b": {"1"}, "a": {"1"}},
+			actual:   Args{},
+			want: []ArgDiscrepancy{
+				{Node: "node", Source: "node-args", Flag: "a", Kind: "dropped", Expected: "1"},
+				{Node: "node", Source: "node-args", Flag: "b", Kind: "dropped", Expected: "1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareArgs("node", "node-args", tt.expected, tt.actual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Args
+	}{
+		{
+			name: "config.yaml only",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml": "write-kubeconfig-mode: 644\ntls-san:\n  - 10.0.0.1\n  - 10.0.0.2\n",
+			},
+			want: Args{"write-kubeconfig-mode": {"644"}, "tls-san": {"10.0.0.1", "10.0.0.2"}},
+		},
+		{
+			name: "drop-in files replace config.yaml in lexical order",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml.d/20-b.yaml": "node-name: from-20\n",
+				"/etc/rancher/k3s/config.yaml":             "node-name: from-config\n",
+				"/etc/rancher/k3s/config.yaml.d/10-a.yaml": "node-name: from-10\n",
+			},
+			want: Args{"node-name": {"from-20"}},
+		},
+		{
+			name: "plus key appends in lexical order",
+			files: map[string]string{
+				"/etc/rancher/rke2/config.yaml.d/20-b.yaml": "kubelet-arg+:\n  - v=4\n",
+				"/etc/rancher/rke2/config.yaml":             "kubelet-arg:\n  - max-pods=250\n",
+				"/etc/rancher/rke2/config.yaml.d/10-a.yaml": "kubelet-arg+: v=2\n",
+			},
+			want: Args{"kubelet-arg": {"max-pods=250", "v=2", "v=4"}},
+		},
+		{
+			name: "key after plus key replaces the appended values",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml":             "node-label+: [a=1]\n",
+				"/etc/rancher/k3s/config.yaml.d/10-a.yaml": "node-label: [b=2]\n",
+			},
+			want: Args{"node-label": {"b=2"}},
+		},
+		{
+			name: "key after plus key in one file replaces it",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml": "node-taint+: [b=2:NoSchedule]\nnode-taint: [a=1:NoSchedule]\n",
+			},
+			want: Args{"node-taint": {"a=1:NoSchedule"}},
+		},
+		{
+			name: "plus key after key in one file appends",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml": "node-taint: [a=1:NoSchedule]\nnode-taint+: [b=2:NoSchedule]\n",
+			},
+			want: Args{"node-taint": {"a=1:NoSchedule", "b=2:NoSchedule"}},
+		},
+		{
+			name: "empty file",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml":             "node-name: server-1\n",
+				"/etc/rancher/k3s/config.yaml.d/10-a.yaml": "",
+			},
+			want: Args{"node-name": {"server-1"}},
+		},
+		{
+			name: "empty value clears the key",
+			files: map[string]string{
+				"/etc/rancher/k3s/config.yaml":             "disable: [traefik]\n",
+				"/etc/rancher/k3s/config.yaml.d/10-a.yaml": "disable:\n",
+			},
+			want: Args{"disable": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeConfig(tt.files)
+			if err != nil {
+				t.Fatalf("MergeConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeConfigInvalidYAML(t *testing.T) {
+	_, err := MergeConfig(map[string]string{"/etc/rancher/k3s/config.yaml": "node-name: [unterminated\n"})
+	if err == nil {
+		t.Fatal("MergeConfig() expected an error for invalid yaml")
+	}
+}
@@ -2,6 +2,7 @@ package shared
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -9,6 +10,10 @@ import (
 	"github.com/rancher/distros-test-framework/pkg/customflag"
 )
 
+// ReportAttachmentEntry is the ginkgo report entry name used by test cases to register
+// local files that should be attached to the spec report, e.g. AddReportEntry(ReportAttachmentEntry, path).
+const ReportAttachmentEntry = "attachment"
+
 type summaryReportData struct {
 	registries    string
 	airgapInfo    string
@@ -97,12 +102,28 @@ func nodeSummaryData(c *Cluster, flags *customflag.FlagConfig, data *summaryRepo
 		data.summaryData.WriteString(nvidiaVersion)
 		data.summaryData.WriteString("\n```\n")
 	}
+	if flags.SUCUpgradeVersion.String() != "" || flags.UpgradePath.Method == "suc" {
+		data.summaryData.WriteString(fmt.Sprintf("\n**SUC Version**: %s\n", flags.SUCControllerVersion.String()))
+	}
+
 	// TODO: ADD split roles data once on airgap is supported.
 	if c.Config.SplitRoles.Enabled {
 		splitRoleData := getSplitRoleData(&c.Config, c.ServerIPs)
 		data.summaryData.WriteString(splitRoleData)
 	}
 
+	if flags.CertificateReport {
+		inventory, certErr := CollectCertificates(c.Config.Product, slices.Concat(c.ServerIPs, c.AgentIPs))
+		if certErr != nil {
+			LogLevel("warn", "certificate inventory not available: %v", certErr)
+		} else {
+			data.summaryData.WriteString("\n" + "**Certificates**" + "\n")
+			data.summaryData.WriteString("```\n")
+			data.summaryData.WriteString(strings.TrimSpace(inventory.String()))
+			data.summaryData.WriteString("\n```\n")
+		}
+	}
+
 	return nil
 }
 
@@ -331,6 +352,21 @@ const (
 	nodeTypeUnknown    NodeType = "unknown"
 )
 
+// HasEtcd returns true when the node runs etcd.
+func (t NodeType) HasEtcd() bool {
+	return t == nodeTypeAllRoles || t == nodeTypeEtcdOnly || t == nodeTypeEtcdCP || t == nodeTypeEtcdWorker
+}
+
+// HasControlPlane returns true when the node runs kube-apiserver, kube-controller-manager and kube-scheduler.
+func (t NodeType) HasControlPlane() bool {
+	return t == nodeTypeAllRoles || t == nodeTypeEtcdCP || t == nodeTypeCPOnly || t == nodeTypeCPWorker
+}
+
+// AcceptsWorkloads returns true when the node has no role taint rejecting workloads.
+func (t NodeType) AcceptsWorkloads() bool {
+	return t != nodeTypeEtcdOnly && t != nodeTypeEtcdCP && t != nodeTypeCPOnly
+}
+
 type nodeConfig struct {
 	DisableAPIServer         bool     `yaml:"disable-apiserver,omitempty"`
 	DisableControllerManager bool     `yaml:"disable-controller-manager,omitempty"`
@@ -385,6 +421,20 @@ func determineRoleFromConfig(configContent string) (NodeType, error) {
 	}
 }
 
+// NodeRole returns the role of the server node from its split role config, all-roles when it has none.
+func NodeRole(product, ip string) (NodeType, error) {
+	cmd := fmt.Sprintf("sudo cat /etc/rancher/%s/config.yaml.d/role_config.yaml 2>/dev/null; true", product)
+	configYaml, err := RunCommandOnNode(cmd, ip)
+	if err != nil {
+		return nodeTypeUnknown, ReturnLogError("failed to read role config on %s: %w", ip, err)
+	}
+	if strings.TrimSpace(configYaml) == "" {
+		return nodeTypeAllRoles, nil
+	}
+
+	return determineRoleFromConfig(configYaml)
+}
+
 func hasLabel(labels []string, label string) bool {
 	for _, l := range labels {
 		if l == label {